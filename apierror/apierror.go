@@ -0,0 +1,156 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/microservices/api/logs"
+)
+
+// ContentType is the media type of the error responses.
+const ContentType = "application/problem+json"
+
+// Code identifies the kind of an API error for the clients.
+type Code string
+
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeValidation       Code = "validation_failed"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeUnsupportedMedia Code = "unsupported_media_type"
+	CodeUnavailable      Code = "unavailable"
+	CodeInternal         Code = "internal"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:       http.StatusBadRequest,
+	CodeValidation:       http.StatusUnprocessableEntity,
+	CodeUnauthorized:     http.StatusUnauthorized,
+	CodeForbidden:        http.StatusForbidden,
+	CodeNotFound:         http.StatusNotFound,
+	CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	CodeConflict:         http.StatusConflict,
+	CodeUnsupportedMedia: http.StatusUnsupportedMediaType,
+	CodeUnavailable:      http.StatusServiceUnavailable,
+	CodeInternal:         http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status of the errors of code.
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an API error, written as an RFC 7807 problem document. Its type
+// is about:blank, the kind of error being given by the code extension
+// member; message repeats the detail for the clients of the former
+// {"message": ...} errors.
+type Error struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"errors,omitempty"`
+	Message   string       `json:"message"`
+
+	// cause is logged, never sent to the client.
+	cause error
+}
+
+// FieldError tells why the value of one field of the request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New returns an error of code described by detail.
+func New(code Code, detail string) *Error {
+	status := Status(code)
+	return &Error{
+		Type:    "about:blank",
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Message: detail,
+	}
+}
+
+func BadRequest(detail string) *Error { return New(CodeBadRequest, detail) }
+
+func Unauthorized(detail string) *Error { return New(CodeUnauthorized, detail) }
+
+func NotFound(detail string) *Error { return New(CodeNotFound, detail) }
+
+func Conflict(detail string) *Error { return New(CodeConflict, detail) }
+
+func Unavailable(detail string) *Error { return New(CodeUnavailable, detail) }
+
+// Invalid returns a validation error listing the invalid fields.
+func Invalid(fields ...FieldError) *Error {
+	return New(CodeValidation, "The request has invalid fields").WithFields(fields...)
+}
+
+// Internal returns an internal error caused by err, which is logged but not
+// disclosed to the client.
+func Internal(err error) *Error {
+	return New(CodeInternal, "Internal error").WithCause(err)
+}
+
+// WithFields adds the fields at fault to e.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+// WithCause records the error that caused e.
+func (e *Error) WithCause(err error) *Error {
+	e.cause = err
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Detail + ": " + e.cause.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Write writes err to w as a problem document. An err that is not an *Error
+// is written as an internal error. The server errors are logged with their
+// cause on the logger of the request.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+	problem := *e
+	problem.Instance = r.URL.Path
+	problem.RequestID = logs.RequestID(r.Context())
+	if problem.Status >= http.StatusInternalServerError {
+		logs.FromContext(r.Context()).Error(e.Error())
+	}
+
+	body, err := json.MarshalIndent(problem, "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(problem.Status), problem.Status)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
@@ -0,0 +1,117 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/microservices/api/logs"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		code Code
+		want int
+	}{
+		{CodeBadRequest, http.StatusBadRequest},
+		{CodeValidation, http.StatusUnprocessableEntity},
+		{CodeUnauthorized, http.StatusUnauthorized},
+		{CodeForbidden, http.StatusForbidden},
+		{CodeNotFound, http.StatusNotFound},
+		{CodeMethodNotAllowed, http.StatusMethodNotAllowed},
+		{CodeConflict, http.StatusConflict},
+		{CodeUnsupportedMedia, http.StatusUnsupportedMediaType},
+		{CodeUnavailable, http.StatusServiceUnavailable},
+		{CodeInternal, http.StatusInternalServerError},
+		{Code("teapot"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := Status(tt.code); got != tt.want {
+			t.Errorf("Status(%q) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cause := errors.New("no reachable servers")
+	tests := []struct {
+		name string
+		err  error
+		want map[string]interface{}
+	}{
+		{
+			name: "not found",
+			err:  NotFound("Ticket not found"),
+			want: map[string]interface{}{
+				"type": "about:blank", "title": "Not Found", "status": 404.0, "detail": "Ticket not found",
+				"instance": "/api/tickets/T1", "code": "not_found", "request_id": "req-1", "message": "Ticket not found",
+			},
+		},
+		{
+			name: "invalid fields",
+			err:  Invalid(FieldError{Field: "sev", Message: "must be 1 to 5"}),
+			want: map[string]interface{}{
+				"type": "about:blank", "title": "Unprocessable Entity", "status": 422.0, "detail": "The request has invalid fields",
+				"instance": "/api/tickets/T1", "code": "validation_failed", "request_id": "req-1", "message": "The request has invalid fields",
+				"errors": []interface{}{map[string]interface{}{"field": "sev", "message": "must be 1 to 5"}},
+			},
+		},
+		{
+			name: "internal hides the cause",
+			err:  Internal(cause),
+			want: map[string]interface{}{
+				"type": "about:blank", "title": "Internal Server Error", "status": 500.0, "detail": "Internal error",
+				"instance": "/api/tickets/T1", "code": "internal", "request_id": "req-1", "message": "Internal error",
+			},
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("assign: %w", Conflict("Ticket changed meanwhile")),
+			want: map[string]interface{}{
+				"type": "about:blank", "title": "Conflict", "status": 409.0, "detail": "Ticket changed meanwhile",
+				"instance": "/api/tickets/T1", "code": "conflict", "request_id": "req-1", "message": "Ticket changed meanwhile",
+			},
+		},
+		{
+			name: "plain error",
+			err:  cause,
+			want: map[string]interface{}{
+				"type": "about:blank", "title": "Internal Server Error", "status": 500.0, "detail": "Internal error",
+				"instance": "/api/tickets/T1", "code": "internal", "request_id": "req-1", "message": "Internal error",
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/tickets/T1", nil)
+		r = r.WithContext(logs.WithRequestID(r.Context(), "req-1"))
+		w := httptest.NewRecorder()
+		Write(w, r, tt.err)
+		if got := w.Header().Get("Content-Type"); got != ContentType {
+			t.Errorf("%s: content type %q, want %q", tt.name, got, ContentType)
+		}
+		if w.Code != int(tt.want["status"].(float64)) {
+			t.Errorf("%s: status %d, want %v", tt.name, w.Code, tt.want["status"])
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: wrote %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInternalError(t *testing.T) {
+	err := Internal(errors.New("no reachable servers"))
+	if got, want := err.Error(), "Internal error: no reachable servers"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if errors.Unwrap(err) == nil {
+		t.Error("the cause is not unwrapped")
+	}
+}
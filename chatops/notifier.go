@@ -0,0 +1,174 @@
+package chatops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	user "github.com/microservices/api/users"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultTemplates are the messages posted when no template is configured
+// for an event type. Events without a template are not posted.
+var DefaultTemplates = map[string]string{
+	events.RotationAdvanced: `{{mention .Mention}} is now on dispatch{{if .Role}} for {{.Role}}{{end}}.`,
+	events.TicketCreated:    `:rotating_light: Sev1 ticket {{.Ticket.Number}} was opened: {{.Ticket.Abstract}} {{mention .Mention}}`,
+	events.TicketEscalated:  `:warning: Ticket {{.Ticket.Number}} ({{.Ticket.Sev}}, {{.Ticket.State}}) is escalated: {{.Reason}}.{{range .Notify}} {{mention .ID}}{{end}}`,
+}
+
+// Message is the data the templates are executed with. Mention holds the
+// chat id of the engineer the event is about.
+type Message struct {
+	events.Event
+	Mention string
+}
+
+// Notifier posts a chat message to an incoming-webhook URL (Slack and
+// Mattermost accept the same payload) when the rotation advances or a Sev1
+// ticket is added.
+type Notifier struct {
+	session   *mgo.Session
+	url       string
+	templates map[string]*template.Template
+	// Client is used to post the messages.
+	Client *http.Client
+}
+
+var funcs = template.FuncMap{
+	"mention": func(id string) string {
+		if id == "" {
+			return ""
+		}
+		return "<@" + id + ">"
+	},
+}
+
+// NewNotifier returns a notifier posting to url. templates overrides the
+// default template of the event types it lists.
+func NewNotifier(s *mgo.Session, url string, templates map[string]string) (*Notifier, error) {
+	n := &Notifier{
+		session:   s,
+		url:       url,
+		templates: make(map[string]*template.Template),
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, set := range []map[string]string{DefaultTemplates, templates} {
+		for t, text := range set {
+			tmpl, err := template.New(t).Funcs(funcs).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("chatops: template of %s: %v", t, err)
+			}
+			n.templates[t] = tmpl
+		}
+	}
+	return n, nil
+}
+
+// IsSev1 reports whether sev names the highest severity ("1", "Sev1", "SEV 1"...).
+func IsSev1(sev string) bool {
+	return ticket.SevLevel(sev) == "1"
+}
+
+func (n *Notifier) wants(e events.Event) bool {
+	if _, ok := n.templates[e.Type]; !ok {
+		return false
+	}
+	if e.Type == events.TicketCreated {
+		return e.Ticket != nil && IsSev1(e.Ticket.Sev)
+	}
+	return true
+}
+
+// Run posts the messages for the events published on bus until it is
+// closed. When it falls behind and the bus drops its subscription, it
+// subscribes again and catches up with the events the bus kept.
+func (n *Notifier) Run(bus *events.Bus) {
+	ch, _ := bus.Subscribe(256)
+	var missed []events.Event
+	last := ""
+	for {
+		for _, e := range missed {
+			n.handle(e)
+			last = e.ID
+		}
+		for e := range ch {
+			n.handle(e)
+			last = e.ID
+		}
+		if bus.Closed() {
+			return
+		}
+		logs.Component("chatops").WithField("event", last).Warn("Fell behind the events, catching up")
+		missed, ch, _ = bus.SubscribeSince(last, 256)
+	}
+}
+
+func (n *Notifier) handle(e events.Event) {
+	if !n.wants(e) {
+		return
+	}
+	if err := n.Notify(e); err != nil {
+		logs.Component("chatops").WithField("event", e.ID).Error("Can't notify: ", err)
+	}
+}
+
+// Notify renders the template of the event type and posts it.
+func (n *Notifier) Notify(e events.Event) error {
+	tmpl, ok := n.templates[e.Type]
+	if !ok {
+		return nil
+	}
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, Message{Event: e, Mention: n.mention(e)}); err != nil {
+		return err
+	}
+	return n.Post(text.String())
+}
+
+// Post sends text to the webhook.
+func (n *Notifier) Post(text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := n.Client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// mention finds the chat id of the engineer concerned by the event: the one
+// taking the turn, the owner of the ticket or else the current dispatcher.
+func (n *Notifier) mention(e events.Event) string {
+	if e.User != nil {
+		return e.User.ID
+	}
+	session := n.session.Copy()
+	defer session.Close()
+	c := store.Users(session)
+	var u user.User
+	if e.Ticket != nil && e.Ticket.Owner != "" {
+		if err := c.Find(store.Live(bson.M{"name": e.Ticket.Owner})).One(&u); err == nil {
+			return u.ID
+		}
+	}
+	if err := c.Find(bson.M{"current": true}).One(&u); err != nil {
+		logs.Component("chatops").Error("Can't get current user: ", err)
+		return ""
+	}
+	return u.ID
+}
@@ -0,0 +1,139 @@
+package chatops
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/microservices/api/events"
+	ticket "github.com/microservices/api/tickets"
+	user "github.com/microservices/api/users"
+)
+
+// chatStub is an incoming webhook recording the texts posted to it.
+type chatStub struct {
+	*httptest.Server
+	texts  []string
+	status int
+}
+
+func newChatStub(t *testing.T) *chatStub {
+	stub := &chatStub{status: http.StatusOK}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("payload is not JSON: %v", err)
+		}
+		stub.texts = append(stub.texts, payload.Text)
+		w.WriteHeader(stub.status)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func TestIsSev1(t *testing.T) {
+	tests := []struct {
+		sev  string
+		want bool
+	}{
+		{"1", true},
+		{"Sev1", true},
+		{"SEV 1", true},
+		{"sev 1", true},
+		{"2", false},
+		{"Sev2", false},
+		{"", false},
+		{"11", false},
+	}
+	for _, tt := range tests {
+		if got := IsSev1(tt.sev); got != tt.want {
+			t.Errorf("IsSev1(%q) = %v, want %v", tt.sev, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierPosts(t *testing.T) {
+	engineer := &user.User{ID: "U1", Name: "jdoe"}
+	tests := []struct {
+		name      string
+		templates map[string]string
+		event     events.Event
+		want      []string
+	}{
+		{
+			name:  "rotation",
+			event: events.Event{Type: events.RotationAdvanced, User: engineer},
+			want:  []string{"<@U1> is now on dispatch."},
+		},
+		{
+			name:  "rotation of a pool",
+			event: events.Event{Type: events.RotationAdvanced, User: engineer, Role: "db"},
+			want:  []string{"<@U1> is now on dispatch for db."},
+		},
+		{
+			name:  "sev1 ticket",
+			event: events.Event{Type: events.TicketCreated, User: engineer, Ticket: &ticket.Ticket{Number: "T1", Sev: "Sev1", Abstract: "down"}},
+			want:  []string{":rotating_light: Sev1 ticket T1 was opened: down <@U1>"},
+		},
+		{
+			name:  "sev2 ticket",
+			event: events.Event{Type: events.TicketCreated, User: engineer, Ticket: &ticket.Ticket{Number: "T2", Sev: "2"}},
+		},
+		{
+			name: "escalation",
+			event: events.Event{Type: events.TicketEscalated, User: engineer, Reason: "queued for 1h",
+				Ticket: &ticket.Ticket{Number: "T3", Sev: "1", State: "Queued"},
+				Notify: []user.User{{ID: "U2"}, {ID: "U3"}}},
+			want: []string{":warning: Ticket T3 (1, Queued) is escalated: queued for 1h. <@U2> <@U3>"},
+		},
+		{
+			name:  "event without template",
+			event: events.Event{Type: events.TicketUpdated, User: engineer, Ticket: &ticket.Ticket{Number: "T4"}},
+		},
+		{
+			name:      "configured template",
+			templates: map[string]string{events.TicketUpdated: "{{.Ticket.Number}} changed"},
+			event:     events.Event{Type: events.TicketUpdated, User: engineer, Ticket: &ticket.Ticket{Number: "T5"}},
+			want:      []string{"T5 changed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newChatStub(t)
+			n, err := NewNotifier(nil, stub.URL, tt.templates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n.handle(tt.event)
+			if len(stub.texts) != len(tt.want) {
+				t.Fatalf("posted %q, want %q", stub.texts, tt.want)
+			}
+			for i := range tt.want {
+				if stub.texts[i] != tt.want[i] {
+					t.Errorf("posted %q, want %q", stub.texts[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierRejectedPost(t *testing.T) {
+	stub := newChatStub(t)
+	stub.status = http.StatusInternalServerError
+	n, err := NewNotifier(nil, stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Post("hello"); err == nil {
+		t.Error("Post succeeded on a 500 response")
+	}
+}
+
+func TestNewNotifierBadTemplate(t *testing.T) {
+	if _, err := NewNotifier(nil, "http://chat.invalid", map[string]string{events.TicketUpdated: "{{.Nope"}); err == nil {
+		t.Error("NewNotifier accepted a template that does not parse")
+	}
+}
@@ -0,0 +1,89 @@
+package chatops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxSkew is how old a signed Slack request may be before it is rejected as
+// a possible replay.
+const MaxSkew = 5 * time.Minute
+
+var (
+	ErrNoSignature  = errors.New("chatops: request is not signed")
+	ErrStale        = errors.New("chatops: request timestamp is too old")
+	ErrBadSignature = errors.New("chatops: request signature mismatch")
+)
+
+// VerifySlack checks the signature Slack puts on the requests it sends,
+// see https://api.slack.com/authentication/verifying-requests-from-slack.
+func VerifySlack(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if ts == "" || signature == "" {
+		return ErrNoSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrNoSignature
+	}
+	if math.Abs(now.Sub(time.Unix(sec, 0)).Seconds()) > MaxSkew.Seconds() {
+		return ErrStale
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Text is a text object of a block.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Block is a layout block of a message.
+type Block struct {
+	Type   string `json:"type"`
+	Text   *Text  `json:"text,omitempty"`
+	Fields []Text `json:"fields,omitempty"`
+}
+
+// Response answers a slash command.
+type Response struct {
+	ResponseType string  `json:"response_type"`
+	Text         string  `json:"text"`
+	Blocks       []Block `json:"blocks,omitempty"`
+}
+
+// Section is a block holding markdown text and optional markdown fields.
+func Section(text string, fields ...string) Block {
+	b := Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: text}}
+	for _, f := range fields {
+		b.Fields = append(b.Fields, Text{Type: "mrkdwn", Text: f})
+	}
+	return b
+}
+
+// Reply is a response visible to the whole channel.
+func Reply(text string, blocks ...Block) Response {
+	if len(blocks) == 0 {
+		blocks = []Block{Section(text)}
+	}
+	return Response{ResponseType: "in_channel", Text: text, Blocks: blocks}
+}
+
+// Ephemeral is a response visible only to the user who ran the command.
+func Ephemeral(text string) Response {
+	return Response{ResponseType: "ephemeral", Text: text, Blocks: []Block{Section(text)}}
+}
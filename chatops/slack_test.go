@@ -0,0 +1,70 @@
+package chatops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySlack(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	body := []byte("command=%2Fdispatch&text=who")
+	ts := strconv.FormatInt(now.Unix(), 10)
+	signature := sign(secret, ts, body)
+	tests := []struct {
+		name      string
+		ts        string
+		signature string
+		now       time.Time
+		want      error
+	}{
+		{"signed", ts, signature, now, nil},
+		{"a little late", ts, signature, now.Add(MaxSkew), nil},
+		{"stale", ts, signature, now.Add(MaxSkew + time.Second), ErrStale},
+		{"from the future", ts, signature, now.Add(-MaxSkew - time.Second), ErrStale},
+		{"other secret", ts, sign("other", ts, body), now, ErrBadSignature},
+		{"other timestamp", strconv.FormatInt(now.Unix()+1, 10), signature, now, ErrBadSignature},
+		{"no signature", ts, "", now, ErrNoSignature},
+		{"no timestamp", "", signature, now, ErrNoSignature},
+		{"bad timestamp", "yesterday", signature, now, ErrNoSignature},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", tt.ts)
+		header.Set("X-Slack-Signature", tt.signature)
+		if err := VerifySlack(secret, header, body, tt.now); err != tt.want {
+			t.Errorf("%s: VerifySlack = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestResponses(t *testing.T) {
+	section := Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: "*T1*"}, Fields: []Text{{Type: "mrkdwn", Text: "*Sev*\n1"}}}
+	tests := []struct {
+		name string
+		got  Response
+		want Response
+	}{
+		{"reply", Reply("hi"), Response{ResponseType: "in_channel", Text: "hi", Blocks: []Block{{Type: "section", Text: &Text{Type: "mrkdwn", Text: "hi"}}}}},
+		{"reply with blocks", Reply("T1", Section("*T1*", "*Sev*\n1")), Response{ResponseType: "in_channel", Text: "T1", Blocks: []Block{section}}},
+		{"ephemeral", Ephemeral("no"), Response{ResponseType: "ephemeral", Text: "no", Blocks: []Block{{Type: "section", Text: &Text{Type: "mrkdwn", Text: "no"}}}}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+}
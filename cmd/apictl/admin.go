@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/microservices/api/migrations"
+	"github.com/microservices/api/store"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// indexesEnsure creates the indexes the service creates on startup, then
+// lists the indexes. The other indexes come with the migrations.
+func indexesEnsure(a *app, args []string) error {
+	if err := store.EnsureIndexes(a.session); err != nil {
+		return err
+	}
+	return indexesList(a, args)
+}
+
+// indexInfo is an index of a collection.
+type indexInfo struct {
+	Collection string   `json:"collection"`
+	Name       string   `json:"name"`
+	Key        []string `json:"key"`
+	Unique     bool     `json:"unique"`
+	Sparse     bool     `json:"sparse"`
+}
+
+func indexesList(a *app, args []string) error {
+	fs, format := flags("list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var infos []indexInfo
+	for _, c := range []*mgo.Collection{
+		store.Tickets(a.session), store.Defects(a.session), store.Users(a.session),
+		store.Availability(a.session), store.Rotations(a.session), store.Webhooks(a.session),
+		store.WebhookDeliveries(a.session), store.JobLeases(a.session), store.JobRuns(a.session),
+		store.Migrations(a.session), store.TicketHistory(a.session),
+	} {
+		indexes, err := c.Indexes()
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.FullName, err)
+		}
+		for _, i := range indexes {
+			infos = append(infos, indexInfo{Collection: c.FullName, Name: i.Name, Key: i.Key, Unique: i.Unique, Sparse: i.Sparse})
+		}
+	}
+	t := table{header: []string{"COLLECTION", "NAME", "KEY", "UNIQUE", "SPARSE"}}
+	for _, i := range infos {
+		t.rows = append(t.rows, []string{i.Collection, i.Name, strings.Join(i.Key, ","), yesNo(i.Unique), yesNo(i.Sparse)})
+	}
+	return output(*format, infos, t)
+}
+
+// ticketsNormalize recomputes the ISO timestamps of the stored tickets, e.g.
+// after the layouts or the timezone of the ticketing system changed.
+func ticketsNormalize(a *app, args []string) error {
+	fs, _ := flags("normalize")
+	dryRun := fs.Bool("dry-run", false, "report the changes without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	env := &migrations.Env{Session: a.session, Normalizer: a.normalizer, DryRun: *dryRun, Out: stdout}
+	return migrations.NormalizeTimestamps(env)
+}
+
+func migrateUp(a *app, args []string) error {
+	fs, _ := flags("up")
+	dryRun := fs.Bool("dry-run", false, "report the changes without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	env := &migrations.Env{Session: a.session, Normalizer: a.normalizer, DryRun: *dryRun, Out: stdout}
+	err := migrations.Up(env, migrations.All)
+	var locked *migrations.LockedError
+	if errors.As(err, &locked) {
+		r := locked.Record
+		return fmt.Errorf("migration %03d %s is being applied by %s since %s; if that runner is gone, remove the document %d from %s and run again",
+			r.Version, r.Name, r.Owner, formatTime(r.Started), r.Version, store.Migrations(a.session).FullName)
+	}
+	return err
+}
+
+// migrationInfo is the state of a migration.
+type migrationInfo struct {
+	Version  int    `json:"version"`
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Owner    string `json:"owner,omitempty"`
+	Finished string `json:"finished,omitempty"`
+}
+
+func migrateStatus(a *app, args []string) error {
+	fs, format := flags("status")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	statuses, err := migrations.Statuses(a.session, migrations.All)
+	if err != nil {
+		return err
+	}
+	infos, t := migrationTable(statuses)
+	return output(*format, infos, t)
+}
+
+// migrationTable returns the state of the migrations of statuses.
+func migrationTable(statuses []migrations.Status) ([]migrationInfo, table) {
+	infos := make([]migrationInfo, len(statuses))
+	t := table{header: []string{"VERSION", "NAME", "STATE", "OWNER", "FINISHED"}}
+	for i, s := range statuses {
+		infos[i] = migrationInfo{Version: s.Version, Name: s.Name, State: "pending"}
+		if s.Record != nil {
+			infos[i].State, infos[i].Owner = "running", s.Record.Owner
+		}
+		if s.Applied() {
+			infos[i].State, infos[i].Finished = "applied", formatTime(s.Record.Finished)
+		}
+		t.rows = append(t.rows, []string{fmt.Sprintf("%03d", s.Version), s.Name, infos[i].State, infos[i].Owner, infos[i].Finished})
+	}
+	return infos, t
+}
@@ -0,0 +1,112 @@
+// Command apictl administers the dispatch service from the command line,
+// working on its database with the configuration of the API:
+//
+//	apictl [-config api.yaml] [settings] <command> <subcommand> [flags] [args]
+//
+// The commands are:
+//
+//	rotation who|next [-role r]          whose turn it is, or pass the turn on
+//	rotation set-current [-role r] <id>  give the turn to a user
+//	users add -id <id> -name <name> ...  add a user
+//	users blacklist|promote <id>         take out of the rotation, make admin
+//	tickets export [-state s]            write the tickets as NDJSON
+//	tickets import [-dry-run] [file]     upsert the tickets of an NDJSON or CSV export
+//	tickets normalize [-dry-run]         recompute the ISO timestamps of the tickets
+//	indexes ensure|list                  create the startup indexes, list them
+//	migrate up|status [-dry-run]         apply or list the migrations
+//	report backlog <YYYY-MM-DD>          the tickets open on a day
+//
+// Most subcommands take -o table (the default) or -o json.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/microservices/api/config"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// app is what the commands run with.
+type app struct {
+	ctx        context.Context
+	session    *mgo.Session
+	normalizer ticket.Normalizer
+}
+
+var commands = map[string]map[string]func(a *app, args []string) error{
+	"rotation": {"who": rotationWho, "next": rotationNext, "set-current": rotationSetCurrent},
+	"users":    {"add": usersAdd, "blacklist": usersBlacklist, "promote": usersPromote},
+	"tickets":  {"export": ticketsExport, "import": ticketsImport, "normalize": ticketsNormalize},
+	"indexes":  {"ensure": indexesEnsure, "list": indexesList},
+	"migrate":  {"up": migrateUp, "status": migrateStatus},
+	"report":   {"backlog": reportBacklog},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "apictl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	conf, args, err := config.Load("apictl", args)
+	if err != nil {
+		return err
+	}
+	cmd, args, err := lookup(args)
+	if err != nil {
+		return err
+	}
+
+	store.Configure(conf)
+	normalizer, err := ticket.NewNormalizer(conf.Tickets.Timezone, conf.Tickets.Layouts)
+	if err != nil {
+		return err
+	}
+	session, err := store.Dial(conf.Mongo)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.SetMode(mgo.Monotonic, true)
+
+	ctx := history.WithActor(context.Background(), "apictl")
+	if u, err := user.Current(); err == nil {
+		ctx = history.WithActor(ctx, "apictl:"+u.Username)
+	}
+	a := &app{ctx: ctx, session: session, normalizer: normalizer}
+	return cmd(a, args)
+}
+
+// lookup returns the subcommand named by args and its arguments, or
+// flag.ErrHelp after printing the usage.
+func lookup(args []string) (func(a *app, args []string) error, []string, error) {
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]][args[1]]; ok {
+			return cmd, args[2:], nil
+		}
+	}
+	return nil, nil, usage()
+}
+
+func usage() error {
+	fmt.Fprintln(stderr, "usage: apictl [-config file] [settings] <command> <subcommand> [flags] [args]")
+	for _, name := range []string{"rotation", "users", "tickets", "indexes", "migrate", "report"} {
+		fmt.Fprintf(stderr, "  %s:", name)
+		for _, sub := range sortedKeys(commands[name]) {
+			fmt.Fprintf(stderr, " %s", sub)
+		}
+		fmt.Fprintln(stderr)
+	}
+	return flag.ErrHelp
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microservices/api/migrations"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		args []string
+		rest []string
+		ok   bool
+	}{
+		{args: []string{"rotation", "who"}, rest: []string{}, ok: true},
+		{args: []string{"migrate", "status", "-o", "json"}, rest: []string{"-o", "json"}, ok: true},
+		{args: []string{"tickets", "import", "-dry-run", "export.csv"}, rest: []string{"-dry-run", "export.csv"}, ok: true},
+		{args: nil},
+		{args: []string{"migrate"}},
+		{args: []string{"migrate", "down"}},
+		{args: []string{"backup", "now"}},
+	}
+	defer func(w io.Writer) { stderr = w }(stderr)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		stderr = &out
+		cmd, rest, err := lookup(tt.args)
+		if tt.ok {
+			if cmd == nil || err != nil || !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("lookup(%q) = %q, %v, want the command with %q", tt.args, rest, err, tt.rest)
+			}
+			if out.Len() > 0 {
+				t.Errorf("lookup(%q) printed the usage", tt.args)
+			}
+			continue
+		}
+		if cmd != nil || err != flag.ErrHelp {
+			t.Errorf("lookup(%q) error %v, want %v", tt.args, err, flag.ErrHelp)
+		}
+		for _, want := range []string{"usage: apictl", "  migrate: status up\n", "  tickets: export import normalize\n"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("usage %q misses %q", out.String(), want)
+			}
+		}
+	}
+}
+
+func TestMigrationTable(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	up := func(*migrations.Env) error { return nil }
+	statuses := []migrations.Status{
+		{Migration: migrations.Migration{Version: 1, Name: "users-id-index", Up: up}, Record: &migrations.Record{Version: 1, Owner: "api-1:7", Done: true, Finished: at}},
+		{Migration: migrations.Migration{Version: 2, Name: "query-indexes", Up: up}, Record: &migrations.Record{Version: 2, Owner: "api-2:9"}},
+		{Migration: migrations.Migration{Version: 3, Name: "tickets-iso-timestamps", Up: up}},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "table",
+			want: "VERSION  NAME                    STATE    OWNER    FINISHED\n" +
+				"001      users-id-index          applied  api-1:7  2026-10-19 09:30\n" +
+				"002      query-indexes           running  api-2:9  \n" +
+				"003      tickets-iso-timestamps  pending           \n",
+		},
+		{
+			format: "json",
+			want: `[
+  {
+    "version": 1,
+    "name": "users-id-index",
+    "state": "applied",
+    "owner": "api-1:7",
+    "finished": "2026-10-19 09:30"
+  },
+  {
+    "version": 2,
+    "name": "query-indexes",
+    "state": "running",
+    "owner": "api-2:9"
+  },
+  {
+    "version": 3,
+    "name": "tickets-iso-timestamps",
+    "state": "pending"
+  }
+]
+`,
+		},
+	}
+	defer func(w io.Writer) { stdout = w }(stdout)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		stdout = &out
+		infos, table := migrationTable(statuses)
+		if err := output(tt.format, infos, table); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("-o %s:\n%s\nwant:\n%s", tt.format, out.String(), tt.want)
+		}
+	}
+	if err := output("yaml", nil, table{}); err == nil {
+		t.Error("output accepted the yaml format")
+	}
+}
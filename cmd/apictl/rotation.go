@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/microservices/api/rotation"
+)
+
+func rotationWho(a *app, args []string) error {
+	fs, format := flags("who")
+	role := fs.String("role", "", "pool of the role instead of the dispatch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	user, err := rotation.Current(a.ctx, a.session, *role)
+	if err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
+
+func rotationNext(a *app, args []string) error {
+	fs, format := flags("next")
+	role := fs.String("role", "", "pool of the role instead of the dispatch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	user, err := rotation.Advance(a.ctx, a.session, *role, time.Now())
+	if err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
+
+func rotationSetCurrent(a *app, args []string) error {
+	fs, format := flags("set-current")
+	role := fs.String("role", "", "pool of the role instead of the dispatch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: rotation set-current [-role r] <id>")
+	}
+	user, err := rotation.SetCurrent(a.ctx, a.session, *role, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microservices/api/importer"
+	"github.com/microservices/api/reports"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func ticketsExport(a *app, args []string) error {
+	fs, _ := flags("export")
+	state := fs.String("state", "", "only the tickets in this state")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := bson.M{}
+	if *state != "" {
+		query["state"] = *state
+	}
+	c := store.Tickets(a.session)
+	iter := c.Find(store.Live(query)).Sort("number").Iter()
+	w := bufio.NewWriter(stdout)
+	enc := json.NewEncoder(w)
+	for {
+		var t ticket.Ticket
+		if !iter.Next(&t) {
+			break
+		}
+		if err := enc.Encode(t); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// ticketsImport upserts the tickets of an NDJSON or CSV export, read from
+// a file or the standard input, as the bulk import of the API does.
+func ticketsImport(a *app, args []string) error {
+	fs, format := flags("import")
+	dryRun := fs.Bool("dry-run", false, "report the changes without writing them")
+	kind := fs.String("format", "", "ndjson or csv, by default from the file name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var in io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+		if *kind == "" {
+			*kind = importer.FormatOf(f.Name())
+		}
+	}
+	if *kind == "" {
+		*kind = importer.NDJSON
+	}
+
+	rows, err := importer.Read(in, *kind)
+	if err != nil {
+		return err
+	}
+	report, err := importer.Import(a.ctx, a.session, a.normalizer, rows, *dryRun)
+	if err != nil {
+		return err
+	}
+	t := table{header: []string{"LINE", "NUMBER", "RESULT", "ERRORS"}}
+	for _, res := range report.Results {
+		msgs := make([]string, len(res.Errors))
+		for i, e := range res.Errors {
+			msgs[i] = strings.TrimSpace(e.Field + " " + e.Message)
+		}
+		t.rows = append(t.rows, []string{strconv.Itoa(res.Line), res.Number, res.Result, strings.Join(msgs, "; ")})
+	}
+	if err := output(*format, report, t); err != nil {
+		return err
+	}
+	if *format == "table" {
+		fmt.Fprintf(stdout, "\n%d inserted, %d updated, %d unchanged, %d rejected\n",
+			report.Inserted, report.Updated, report.Unchanged, report.Rejected)
+	}
+	return nil
+}
+
+func reportBacklog(a *app, args []string) error {
+	fs, format := flags("backlog")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: report backlog <YYYY-MM-DD>")
+	}
+	day, err := time.Parse("2006-01-02", fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("date must be YYYY-MM-DD")
+	}
+	tickets, err := reports.Backlog(a.ctx, a.session, day)
+	if err != nil {
+		return err
+	}
+	t := table{header: []string{"NUMBER", "SEV", "STATE", "OWNER", "OPENED", "CLOSED", "ABSTRACT"}}
+	for _, tk := range tickets {
+		t.rows = append(t.rows, []string{tk.Number, tk.Sev, tk.State, tk.Owner,
+			formatTime(tk.ISOOpened), formatTime(tk.ISOClosed), tk.Abstract})
+	}
+	return output(*format, tickets, t)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/tracing"
+	u "github.com/microservices/api/users"
+	"github.com/microservices/api/validate"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func userTable(users ...u.User) table {
+	t := table{header: []string{"ID", "NAME", "REAL NAME", "ACTIVE", "CURRENT", "ADMIN", "ENGINEER", "ROLES"}}
+	for _, user := range users {
+		t.rows = append(t.rows, []string{user.ID, user.Name, user.RealName, yesNo(user.Active),
+			yesNo(user.Current), yesNo(user.IsAdmin), yesNo(user.Engineer), strings.Join(user.Roles, ",")})
+	}
+	return t
+}
+
+func usersAdd(a *app, args []string) error {
+	fs, format := flags("add")
+	var user u.User
+	var roles string
+	fs.StringVar(&user.ID, "id", "", "user id")
+	fs.StringVar(&user.Name, "name", "", "chat name")
+	fs.StringVar(&user.RealName, "real-name", "", "full name")
+	fs.StringVar(&user.Attuid, "attuid", "", "corporate id")
+	fs.StringVar(&roles, "roles", "", "comma separated roles")
+	fs.BoolVar(&user.Engineer, "engineer", true, "takes part in the rotation")
+	fs.BoolVar(&user.IsAdmin, "admin", false, "administrator")
+	fs.BoolVar(&user.Active, "active", true, "active, not blacklisted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if roles != "" {
+		user.Roles = strings.Split(roles, ",")
+	}
+	if errs := validate.Struct(&user); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Field + " " + e.Message
+		}
+		return fmt.Errorf("invalid user: %s", strings.Join(msgs, "; "))
+	}
+
+	c := store.Users(a.session)
+	var n int
+	err := tracing.MongoOp(a.ctx, c, "count", func() (err error) {
+		n, err = c.Find(bson.M{"id": user.ID}).Count()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("user %s already exists", user.ID)
+	}
+	if err := tracing.MongoOp(a.ctx, c, "insert", func() error { return c.Insert(user) }); err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
+
+func usersBlacklist(a *app, args []string) error {
+	fs, format := flags("blacklist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: users blacklist <id>")
+	}
+	user, err := rotation.Blacklist(a.ctx, a.session, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
+
+func usersPromote(a *app, args []string) error {
+	fs, format := flags("promote")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: users promote <id>")
+	}
+	c := store.Users(a.session)
+	uid := fs.Arg(0)
+	err := tracing.MongoOp(a.ctx, c, "update", func() error {
+		return c.Update(store.Live(bson.M{"id": uid}), bson.M{"$set": bson.M{"admin": true}})
+	})
+	if err != nil {
+		return err
+	}
+	var user u.User
+	err = tracing.MongoOp(a.ctx, c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
+	if err != nil {
+		return err
+	}
+	return output(*format, user, userTable(user))
+}
@@ -0,0 +1,433 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microservices/api/tickets"
+	log "github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the API service. Every setting has a key in
+// the configuration file, named after its yaml tag, a flag of the same name
+// and, for most of them, an environment variable given by its env tag.
+type Config struct {
+	Log        Log        `yaml:"log" toml:"log"`
+	HTTP       HTTP       `yaml:"http" toml:"http"`
+	Mongo      Mongo      `yaml:"mongo" toml:"mongo"`
+	Tickets    Tickets    `yaml:"tickets" toml:"tickets"`
+	Rotation   Rotation   `yaml:"rotation" toml:"rotation"`
+	Auth       Auth       `yaml:"auth" toml:"auth"`
+	CORS       CORS       `yaml:"cors" toml:"cors"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	Chat       Chat       `yaml:"chat" toml:"chat"`
+	Digest     Digest     `yaml:"digest" toml:"digest"`
+	Escalation Escalation `yaml:"escalation" toml:"escalation"`
+	Retention  Retention  `yaml:"retention" toml:"retention"`
+	Features   Features   `yaml:"features" toml:"features"`
+}
+
+type Log struct {
+	// Level is a logrus level: debug, info, warning, error...
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+}
+
+type HTTP struct {
+	// Port is required to serve, not to run the maintenance commands.
+	Port        string `yaml:"port" toml:"port" env:"PORT"`
+	ProfilePort string `yaml:"profile_port" toml:"profile_port" env:"PROFILE_PORT"`
+	// ReadTimeout bounds reading a request, headers included.
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+}
+
+type Mongo struct {
+	// URL is a mongodb:// URL or a host:port list.
+	URL        string `yaml:"url" toml:"url" env:"MONGO_HOST"`
+	Username   string `yaml:"username" toml:"username" env:"MONGO_USERNAME"`
+	Password   string `yaml:"password" toml:"password" env:"MONGO_PASSWORD"`
+	AuthSource string `yaml:"auth_source" toml:"auth_source" env:"MONGO_AUTH_SOURCE"`
+	TLS        TLS    `yaml:"tls" toml:"tls"`
+	// DialTimeout bounds connecting to the servers.
+	DialTimeout Duration `yaml:"dial_timeout" toml:"dial_timeout" env:"MONGO_DIAL_TIMEOUT"`
+	// OpTimeout bounds every read and write on a connection.
+	OpTimeout Duration `yaml:"op_timeout" toml:"op_timeout" env:"MONGO_OP_TIMEOUT"`
+	// MigrateOnStart applies the pending migrations before the service is
+	// ready; otherwise they are applied with apictl migrate up.
+	MigrateOnStart bool        `yaml:"migrate_on_start" toml:"migrate_on_start" env:"MONGO_MIGRATE"`
+	Collections    Collections `yaml:"collections" toml:"collections"`
+}
+
+type TLS struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"MONGO_TLS"`
+	// CAFile is a PEM bundle of the authorities trusted besides the system
+	// ones.
+	CAFile             string `yaml:"ca_file" toml:"ca_file" env:"MONGO_TLS_CA_FILE"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify" env:"MONGO_TLS_INSECURE"`
+}
+
+// Collections names the collections of the service as "database.collection".
+type Collections struct {
+	Tickets           string `yaml:"tickets" toml:"tickets"`
+	Defects           string `yaml:"defects" toml:"defects"`
+	Users             string `yaml:"users" toml:"users"`
+	Availability      string `yaml:"availability" toml:"availability"`
+	Rotations         string `yaml:"rotations" toml:"rotations"`
+	Webhooks          string `yaml:"webhooks" toml:"webhooks"`
+	WebhookDeliveries string `yaml:"webhook_deliveries" toml:"webhook_deliveries"`
+	WebhookQueue      string `yaml:"webhook_queue" toml:"webhook_queue"`
+	JobLeases         string `yaml:"job_leases" toml:"job_leases"`
+	JobRuns           string `yaml:"job_runs" toml:"job_runs"`
+	Migrations        string `yaml:"migrations" toml:"migrations"`
+	TicketHistory     string `yaml:"ticket_history" toml:"ticket_history"`
+}
+
+// Tickets describes the tickets as written by the ticketing system: the
+// states the service acts on and how the timestamps are written.
+type Tickets struct {
+	Queued    string `yaml:"queued_state" toml:"queued_state"`
+	Closed    string `yaml:"closed_state" toml:"closed_state"`
+	Cancelled string `yaml:"cancelled_state" toml:"cancelled_state"`
+	// Timezone is the IANA name of the timezone of the timestamps.
+	Timezone string `yaml:"timezone" toml:"timezone" env:"TICKETS_TIMEZONE"`
+	// Layouts overrides the accepted Go time layouts of the timestamp fields
+	// (opened, lastmodified, closed, parent.created, ith.time, logs.date),
+	// tried in order. It is only read from the configuration file.
+	Layouts map[string][]string `yaml:"layouts" toml:"layouts"`
+}
+
+type Rotation struct {
+	// ShiftLength is the duration of one dispatch turn.
+	ShiftLength Duration `yaml:"shift_length" toml:"shift_length" env:"SHIFT_LENGTH"`
+}
+
+// Auth modes.
+const (
+	AuthNone  = "none"
+	AuthProxy = "proxy"
+	AuthBasic = "basic"
+)
+
+type Auth struct {
+	// Mode is "none", "proxy" to require the user set by an authenticating
+	// proxy, or "basic" to check basic auth against Users.
+	Mode string `yaml:"mode" toml:"mode" env:"AUTH_MODE"`
+	// Users maps the basic auth user names to their passwords. It is only
+	// read from the configuration file.
+	Users map[string]string `yaml:"users" toml:"users"`
+	// TrustedProxies lists the addresses or CIDR ranges of the
+	// authenticating proxies; in proxy mode, the user headers are only read
+	// from the requests they send.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"AUTH_TRUSTED_PROXIES"`
+}
+
+// Proxies parses the trusted proxies.
+func (a Auth) Proxies() ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range a.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an address or a CIDR range", p)
+			}
+			bits := 8 * len(ip)
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or a CIDR range", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+type CORS struct {
+	// AllowedOrigins lists the origins allowed to call the API, "*" for any;
+	// CORS is disabled when it is empty.
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders []string `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	MaxAge         Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type Tracing struct {
+	// Exporter is "none", "stdout" or "otlp".
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+}
+
+type Chat struct {
+	WebhookURL string `yaml:"webhook_url" toml:"webhook_url" env:"CHAT_WEBHOOK_URL"`
+	// Templates is the path of a JSON object mapping event types to message
+	// templates.
+	Templates          string `yaml:"templates" toml:"templates" env:"CHAT_TEMPLATES"`
+	SlackSigningSecret string `yaml:"slack_signing_secret" toml:"slack_signing_secret" env:"SLACK_SIGNING_SECRET"`
+}
+
+type Digest struct {
+	SMTPAddr     string   `yaml:"smtp_addr" toml:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPUser     string   `yaml:"smtp_user" toml:"smtp_user" env:"SMTP_USER"`
+	SMTPPassword string   `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD"`
+	From         string   `yaml:"from" toml:"from" env:"DIGEST_FROM"`
+	To           []string `yaml:"to" toml:"to" env:"DIGEST_TO"`
+	Schedule     string   `yaml:"schedule" toml:"schedule" env:"DIGEST_SCHEDULE"`
+}
+
+type Escalation struct {
+	// Rules is the path of a JSON file of rules replacing the default ones.
+	Rules    string `yaml:"rules" toml:"rules" env:"ESCALATION_RULES"`
+	Schedule string `yaml:"schedule" toml:"schedule" env:"ESCALATION_SCHEDULE"`
+}
+
+// Retention is how long the deleted tickets and users can be restored
+// before they are purged.
+type Retention struct {
+	Period   Duration `yaml:"period" toml:"period" env:"RETENTION_PERIOD"`
+	Schedule string   `yaml:"schedule" toml:"schedule" env:"RETENTION_SCHEDULE"`
+}
+
+// Features switches the optional parts of the service on and off.
+type Features struct {
+	Metrics    bool `yaml:"metrics" toml:"metrics" env:"FEATURE_METRICS"`
+	Events     bool `yaml:"events" toml:"events" env:"FEATURE_EVENTS"`
+	Webhooks   bool `yaml:"webhooks" toml:"webhooks" env:"FEATURE_WEBHOOKS"`
+	ChatOps    bool `yaml:"chatops" toml:"chatops" env:"FEATURE_CHATOPS"`
+	Digest     bool `yaml:"digest" toml:"digest" env:"FEATURE_DIGEST"`
+	Escalation bool `yaml:"escalation" toml:"escalation" env:"FEATURE_ESCALATION"`
+	// Retention purges the deleted tickets and users for good. It is off
+	// unless turned on, since a purge cannot be undone.
+	Retention bool `yaml:"retention" toml:"retention" env:"FEATURE_RETENTION"`
+}
+
+// Default returns the configuration used for the settings that are not set.
+func Default() Config {
+	return Config{
+		Log: Log{Level: "info"},
+		HTTP: HTTP{
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(120 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Mongo: Mongo{
+			DialTimeout:    Duration(10 * time.Second),
+			OpTimeout:      Duration(time.Minute),
+			MigrateOnStart: true,
+			Collections: Collections{
+				Tickets:           "info.tickets",
+				Defects:           "info.defect",
+				Users:             "users.users",
+				Availability:      "users.availability",
+				Rotations:         "users.rotations",
+				Webhooks:          "info.webhooks",
+				WebhookDeliveries: "info.webhook_deliveries",
+				WebhookQueue:      "info.webhook_queue",
+				JobLeases:         "info.job_leases",
+				JobRuns:           "info.job_runs",
+				Migrations:        "info.migrations",
+				TicketHistory:     "info.ticket_history",
+			},
+		},
+		Tickets: Tickets{
+			Queued:    "Queued",
+			Closed:    "Closed",
+			Cancelled: "Cancel",
+			Timezone:  "UTC",
+		},
+		Rotation: Rotation{ShiftLength: Duration(24 * time.Hour)},
+		Auth:     Auth{Mode: AuthNone},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Request-ID"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Tracing:    Tracing{Exporter: "none"},
+		Digest:     Digest{Schedule: "0 7 * * *"},
+		Escalation: Escalation{Schedule: "@every 1m"},
+		Retention:  Retention{Period: Duration(30 * 24 * time.Hour), Schedule: "0 3 * * *"},
+		Features: Features{
+			Metrics:    true,
+			Events:     true,
+			Webhooks:   true,
+			ChatOps:    true,
+			Digest:     true,
+			Escalation: true,
+		},
+	}
+}
+
+// ValidationError lists every invalid setting of a configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
+// Validate checks the configuration and returns a ValidationError listing
+// all the problems found.
+func (c Config) Validate() error {
+	var errs ValidationError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		fail("log.level: %v", err)
+	}
+
+	if c.HTTP.Port == "" {
+		fail("http.port: not set")
+	} else if !validPort(c.HTTP.Port) {
+		fail("http.port: %q is not a port number", c.HTTP.Port)
+	}
+	if c.HTTP.ProfilePort != "" && !validPort(c.HTTP.ProfilePort) {
+		fail("http.profile_port: %q is not a port number", c.HTTP.ProfilePort)
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+		{"mongo.dial_timeout", c.Mongo.DialTimeout},
+		{"mongo.op_timeout", c.Mongo.OpTimeout},
+		{"cors.max_age", c.CORS.MaxAge},
+	} {
+		if d.value < 0 {
+			fail("%s: must not be negative", d.name)
+		}
+	}
+
+	if c.Mongo.URL == "" {
+		fail("mongo.url: not set")
+	}
+	if c.Mongo.Password != "" && c.Mongo.Username == "" {
+		fail("mongo.password: set without mongo.username")
+	}
+	if c.Mongo.TLS.CAFile != "" && !c.Mongo.TLS.Enabled {
+		fail("mongo.tls.ca_file: set while TLS is disabled")
+	}
+	for _, coll := range []struct {
+		name string
+		full string
+	}{
+		{"tickets", c.Mongo.Collections.Tickets},
+		{"defects", c.Mongo.Collections.Defects},
+		{"users", c.Mongo.Collections.Users},
+		{"availability", c.Mongo.Collections.Availability},
+		{"rotations", c.Mongo.Collections.Rotations},
+		{"webhooks", c.Mongo.Collections.Webhooks},
+		{"webhook_deliveries", c.Mongo.Collections.WebhookDeliveries},
+		{"webhook_queue", c.Mongo.Collections.WebhookQueue},
+		{"job_leases", c.Mongo.Collections.JobLeases},
+		{"job_runs", c.Mongo.Collections.JobRuns},
+		{"migrations", c.Mongo.Collections.Migrations},
+		{"ticket_history", c.Mongo.Collections.TicketHistory},
+	} {
+		if db, name := SplitCollection(coll.full); db == "" || name == "" {
+			fail("mongo.collections.%s: %q is not database.collection", coll.name, coll.full)
+		}
+	}
+
+	if c.Tickets.Queued == "" || c.Tickets.Closed == "" || c.Tickets.Cancelled == "" {
+		fail("tickets: the queued, closed and cancelled states must be set")
+	}
+	if _, err := tickets.NewNormalizer(c.Tickets.Timezone, c.Tickets.Layouts); err != nil {
+		fail("tickets: %v", err)
+	}
+	if c.Rotation.ShiftLength <= 0 {
+		fail("rotation.shift_length: must be positive")
+	}
+
+	switch c.Auth.Mode {
+	case AuthNone:
+	case AuthProxy:
+		if len(c.Auth.TrustedProxies) == 0 {
+			fail("auth.trusted_proxies: proxy auth needs the addresses of the proxies")
+		}
+	case AuthBasic:
+		if len(c.Auth.Users) == 0 {
+			fail("auth.users: basic auth needs at least one user")
+		}
+	default:
+		fail("auth.mode: %q is not none, proxy or basic", c.Auth.Mode)
+	}
+	if _, err := c.Auth.Proxies(); err != nil {
+		fail("auth.trusted_proxies: %v", err)
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("cors.allowed_origins: %q is not an origin", origin)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		fail("tracing.exporter: %q is not none, stdout or otlp", c.Tracing.Exporter)
+	}
+
+	if len(c.Digest.To) > 0 && c.Digest.SMTPAddr == "" {
+		fail("digest.smtp_addr: needed to send the digest")
+	}
+	if c.Escalation.Schedule == "" {
+		fail("escalation.schedule: not set")
+	}
+	if c.Retention.Period <= 0 {
+		fail("retention.period: must be positive")
+	}
+	if c.Retention.Schedule == "" {
+		fail("retention.schedule: not set")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// SplitCollection splits a "database.collection" name.
+func SplitCollection(full string) (db, collection string) {
+	i := strings.Index(full, ".")
+	if i < 0 {
+		return "", full
+	}
+	return full[:i], full[i+1:]
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+// Duration is a time.Duration written as "30s" or "1h30m" in the
+// configuration file.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file when the -config flag is not given.
+const FileEnv = "CONFIG_FILE"
+
+// Load builds the configuration from the defaults, then the configuration
+// file, then the environment and last the command line flags of args, each
+// source overriding the previous ones, and validates it. It also returns the
+// arguments left after the flags.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+	settings := fields(&cfg)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(FileEnv), "configuration file, YAML or TOML")
+	given := make(map[string]string)
+	for _, s := range settings {
+		usage := s.key
+		if s.env != "" {
+			usage += " (env " + s.env + ")"
+		}
+		fs.Var(flagValue{key: s.key, def: s.String(), isBool: s.v.Kind() == reflect.Bool, given: given}, s.key, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return cfg, nil, err
+		}
+	}
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.Set(v); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %v", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := given[s.key]; ok {
+			if err := s.Set(v); err != nil {
+				return cfg, nil, fmt.Errorf("-%s: %v", s.key, err)
+			}
+		}
+	}
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("%s: unknown configuration format, want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// setting is a single value of the configuration.
+type setting struct {
+	key string
+	env string
+	v   reflect.Value
+}
+
+// fields lists the settings of cfg that can be given as a string: everything
+// but the maps.
+func fields(cfg *Config) []setting {
+	var settings []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := prefix + strings.Split(f.Tag.Get("yaml"), ",")[0]
+			fv := v.Field(i)
+			if _, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); !ok && f.Type.Kind() == reflect.Struct {
+				walk(key+".", fv)
+				continue
+			}
+			if f.Type.Kind() == reflect.Map {
+				continue
+			}
+			settings = append(settings, setting{key: key, env: f.Tag.Get("env"), v: fv})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return settings
+}
+
+func (s setting) String() string {
+	switch v := s.v.Addr().Interface().(type) {
+	case *[]string:
+		return strings.Join(*v, ",")
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(s.v.Interface())
+}
+
+// Set parses value into the setting; lists are comma separated.
+func (s setting) Set(value string) error {
+	if u, ok := s.v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	switch s.v.Kind() {
+	case reflect.String:
+		s.v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		s.v.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.v.Type())
+	}
+	return nil
+}
+
+// flagValue records the flags given on the command line, to apply them once
+// the file and the environment are loaded.
+type flagValue struct {
+	key    string
+	def    string
+	isBool bool
+	given  map[string]string
+}
+
+func (f flagValue) String() string {
+	if f.given == nil {
+		return ""
+	}
+	if v, ok := f.given[f.key]; ok {
+		return v
+	}
+	return f.def
+}
+
+func (f flagValue) Set(value string) error {
+	f.given[f.key] = value
+	return nil
+}
+
+// IsBoolFlag lets the boolean settings be given as a bare -flag.
+func (f flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
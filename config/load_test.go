@@ -0,0 +1,153 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "api.yaml")
+	data := "log:\n  level: warn\nhttp:\n  port: \"8001\"\n  profile_port: \"6001\"\nmongo:\n  url: mongo-file\n"
+	if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want func(c *Config)
+	}{
+		{
+			name: "defaults",
+			args: []string{"-mongo.url", "mongo-flag", "-http.port", "8000"},
+			want: func(c *Config) { c.Mongo.URL, c.HTTP.Port = "mongo-flag", "8000" },
+		},
+		{
+			name: "file over defaults",
+			args: []string{"-config", file},
+			want: func(c *Config) {
+				c.Log.Level, c.HTTP.Port, c.HTTP.ProfilePort, c.Mongo.URL = "warn", "8001", "6001", "mongo-file"
+			},
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"PORT": "8002", "MONGO_HOST": "mongo-env", FileEnv: file},
+			want: func(c *Config) {
+				c.Log.Level, c.HTTP.Port, c.HTTP.ProfilePort, c.Mongo.URL = "warn", "8002", "6001", "mongo-env"
+			},
+		},
+		{
+			name: "flags over env",
+			env:  map[string]string{"PORT": "8002", "MONGO_HOST": "mongo-env", "CORS_ALLOWED_ORIGINS": "https://a.example"},
+			args: []string{"-config", file, "-http.port", "8003", "-cors.allowed_origins", "https://b.example,https://c.example"},
+			want: func(c *Config) {
+				c.Log.Level, c.HTTP.Port, c.HTTP.ProfilePort, c.Mongo.URL = "warn", "8003", "6001", "mongo-env"
+				c.CORS.AllowedOrigins = []string{"https://b.example", "https://c.example"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+			}
+			defer func() {
+				for k := range tt.env {
+					os.Unsetenv(k)
+				}
+			}()
+			got, rest, err := Load("api", append(tt.args, "serve"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load = %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(rest, []string{"serve"}) {
+				t.Errorf("arguments left %q, want [serve]", rest)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Mongo.URL = "localhost"
+	valid.HTTP.Port = "8000"
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   ValidationError
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{
+			name: "every error",
+			change: func(c *Config) {
+				c.Log.Level = "loud"
+				c.HTTP.Port = ""
+				c.Mongo.URL = ""
+				c.Mongo.Collections.Users = "users"
+				c.Auth.Mode = AuthBasic
+				c.Tracing.Exporter = "jaeger"
+			},
+			want: ValidationError{
+				`log.level: not a valid logrus Level: "loud"`,
+				"http.port: not set",
+				"mongo.url: not set",
+				`mongo.collections.users: "users" is not database.collection`,
+				"auth.users: basic auth needs at least one user",
+				`tracing.exporter: "jaeger" is not none, stdout or otlp`,
+			},
+		},
+		{
+			name:   "bad port",
+			change: func(c *Config) { c.HTTP.Port = "http" },
+			want:   ValidationError{`http.port: "http" is not a port number`},
+		},
+		{
+			name:   "proxy auth without proxies",
+			change: func(c *Config) { c.Auth.Mode = AuthProxy },
+			want:   ValidationError{"auth.trusted_proxies: proxy auth needs the addresses of the proxies"},
+		},
+		{
+			name: "proxy auth",
+			change: func(c *Config) {
+				c.Auth.Mode, c.Auth.TrustedProxies = AuthProxy, []string{"10.0.0.1", "192.168.0.0/16", "::1"}
+			},
+		},
+		{
+			name: "bad proxy",
+			change: func(c *Config) {
+				c.Auth.Mode, c.Auth.TrustedProxies = AuthProxy, []string{"10.0.0.300"}
+			},
+			want: ValidationError{`auth.trusted_proxies: "10.0.0.300" is not an address or a CIDR range`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.change(&c)
+			err := c.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("Validate() = %#v, want %#v", err, tt.want)
+			}
+		})
+	}
+}
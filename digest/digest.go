@@ -0,0 +1,22 @@
+package digest
+
+import (
+	"fmt"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Send builds the report of the day before now and mails it.
+func Send(session *mgo.Session, mailer Mailer, now time.Time) error {
+	report, err := Build(session, now)
+	if err != nil {
+		return err
+	}
+	text, html, err := Render(report)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Backlog digest %s: %d open, %d new, %d closed", now.Format("2006-01-02"), len(report.Backlog), report.New, report.Closed)
+	return mailer.Send(subject, text, html)
+}
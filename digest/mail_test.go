@@ -0,0 +1,200 @@
+package digest
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+)
+
+// smtpCapture is an SMTP relay keeping the messages it is sent.
+type smtpCapture struct {
+	ln       net.Listener
+	from     string
+	to       []string
+	messages chan string
+}
+
+func newSMTPCapture(t *testing.T) *smtpCapture {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpCapture{ln: ln, messages: make(chan string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpCapture) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP capture")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			s.from = address(line)
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, address(line))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.messages <- string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func address(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestMailerSend(t *testing.T) {
+	relay := newSMTPCapture(t)
+	m := Mailer{
+		Addr: relay.ln.Addr().String(),
+		From: "api@example.com",
+		To:   []string{"ops@example.com", "lead@example.com"},
+	}
+	if err := m.Send("Backlog digest: 3 open", "plain\nbody", "<p>html</p>"); err != nil {
+		t.Fatal(err)
+	}
+	var raw string
+	select {
+	case raw = <-relay.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the relay got no message")
+	}
+	if relay.from != m.From {
+		t.Errorf("MAIL FROM %q, want %q", relay.from, m.From)
+	}
+	if strings.Join(relay.to, ",") != strings.Join(m.To, ",") {
+		t.Errorf("RCPT TO %q, want %q", relay.to, m.To)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Backlog digest: 3 open" {
+		t.Errorf("Subject %q (%v), want %q", subject, err, "Backlog digest: 3 open")
+	}
+	if to := msg.Header.Get("To"); to != "ops@example.com, lead@example.com" {
+		t.Errorf("To %q", to)
+	}
+	media, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || media != "multipart/alternative" {
+		t.Fatalf("Content-Type %q (%v), want multipart/alternative", media, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ kind, body string }{
+		{"text/plain; charset=utf-8", "plain\nbody"},
+		{"text/html; charset=utf-8", "<p>html</p>"},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("missing %s part: %v", want.kind, err)
+		}
+		body, _ := io.ReadAll(bufio.NewReader(part))
+		if part.Header.Get("Content-Type") != want.kind || string(body) != want.body {
+			t.Errorf("part %q %q, want %q %q", part.Header.Get("Content-Type"), body, want.kind, want.body)
+		}
+	}
+}
+
+func TestMailerSendUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	m := Mailer{Addr: addr, From: "api@example.com", To: []string{"ops@example.com"}}
+	if err := m.Send("subject", "text", "html"); err == nil {
+		t.Error("Send succeeded without a relay")
+	}
+}
+
+func TestRender(t *testing.T) {
+	opened := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report Report
+		text   []string
+		html   []string
+	}{
+		{
+			name:   "empty backlog",
+			report: Report{Date: opened},
+			text:   []string{"Backlog digest for 2026-10-18", "Open tickets: 0", "New since yesterday: 0"},
+			html:   []string{"<h2>Backlog digest for 2026-10-18</h2>"},
+		},
+		{
+			name: "queues",
+			report: Report{
+				Date:    opened,
+				Backlog: []ticket.Ticket{{Number: "T1"}, {Number: "T2"}},
+				New:     1,
+				Closed:  2,
+				Queues: []Queue{
+					{Owner: "", Tickets: []ticket.Ticket{{Number: "T1", Sev: "2", State: "Queued", Abstract: "disk <full>", ISOOpened: opened}}},
+					{Owner: "jdoe", Tickets: []ticket.Ticket{{Number: "T2", Sev: "1", State: "Open", ISOOpened: opened}}},
+				},
+			},
+			text: []string{"Open tickets: 2", "Closed since yesterday: 2", "Unassigned (1)", "  T1  2  Queued  disk <full>", "jdoe (1)"},
+			html: []string{"<h3>Unassigned (1)</h3>", "<td>disk &lt;full&gt;</td>", "<td>2026-10-18 09:30</td>", "<h3>jdoe (1)</h3>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, html, err := Render(tt.report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(text, want) {
+					t.Errorf("text digest misses %q:\n%s", want, text)
+				}
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(html, want) {
+					t.Errorf("HTML digest misses %q:\n%s", want, html)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package digest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Queue is the open tickets of one engineer.
+type Queue struct {
+	Owner   string
+	Tickets []ticket.Ticket
+}
+
+// Report is the content of a digest.
+type Report struct {
+	Date    time.Time
+	Since   time.Time
+	Backlog []ticket.Ticket
+	New     int
+	Closed  int
+	Queues  []Queue
+}
+
+// Build gathers the report of the day before now.
+func Build(s *mgo.Session, now time.Time) (Report, error) {
+	session := s.Copy()
+	defer session.Close()
+	c := store.Tickets(session)
+
+	report := Report{Date: now, Since: now.Add(-24 * time.Hour)}
+	err := c.Find(store.Open()).Select(bson.M{"number": 1, "owner": 1, "sev": 1, "state": 1, "isoopened": 1, "abstract": 1}).Sort("isoopened").All(&report.Backlog)
+	if err != nil {
+		return report, err
+	}
+	if report.New, err = c.Find(store.Live(bson.M{"isoopened": bson.M{"$gte": report.Since}})).Count(); err != nil {
+		return report, err
+	}
+	if report.Closed, err = c.Find(bson.M{"$and": []bson.M{bson.M{"state": store.Closed()}, bson.M{"isoclosed": bson.M{"$gte": report.Since}}, store.NotDeleted()}}).Count(); err != nil {
+		return report, err
+	}
+
+	queues := make(map[string]*Queue)
+	for _, t := range report.Backlog {
+		q, ok := queues[t.Owner]
+		if !ok {
+			q = &Queue{Owner: t.Owner}
+			queues[t.Owner] = q
+		}
+		q.Tickets = append(q.Tickets, t)
+	}
+	for _, q := range queues {
+		report.Queues = append(report.Queues, *q)
+	}
+	sort.Slice(report.Queues, func(i, j int) bool { return report.Queues[i].Owner < report.Queues[j].Owner })
+	return report, nil
+}
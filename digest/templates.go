@@ -0,0 +1,54 @@
+package digest
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+const textDigest = `Backlog digest for {{.Date.Format "2006-01-02"}}
+
+Open tickets: {{len .Backlog}}
+New since yesterday: {{.New}}
+Closed since yesterday: {{.Closed}}
+{{range .Queues}}
+{{if .Owner}}{{.Owner}}{{else}}Unassigned{{end}} ({{len .Tickets}})
+{{range .Tickets}}  {{.Number}}  {{.Sev}}  {{.State}}  {{.Abstract}}
+{{end}}{{end}}`
+
+const htmlDigest = `<!DOCTYPE html>
+<html>
+<body>
+<h2>Backlog digest for {{.Date.Format "2006-01-02"}}</h2>
+<table>
+<tr><td>Open tickets</td><td>{{len .Backlog}}</td></tr>
+<tr><td>New since yesterday</td><td>{{.New}}</td></tr>
+<tr><td>Closed since yesterday</td><td>{{.Closed}}</td></tr>
+</table>
+{{range .Queues}}
+<h3>{{if .Owner}}{{.Owner}}{{else}}Unassigned{{end}} ({{len .Tickets}})</h3>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>Number</th><th>Sev</th><th>State</th><th>Opened</th><th>Abstract</th></tr>
+{{range .Tickets}}<tr><td>{{.Number}}</td><td>{{.Sev}}</td><td>{{.State}}</td><td>{{.ISOOpened.Format "2006-01-02 15:04"}}</td><td>{{.Abstract}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`
+
+var (
+	textTemplate = template.Must(template.New("text").Parse(textDigest))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(htmlDigest))
+)
+
+// Render returns the plain-text and HTML versions of the report.
+func Render(report Report) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, report); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplate.Execute(&html, report); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
@@ -0,0 +1,116 @@
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	user "github.com/microservices/api/users"
+	log "github.com/sirupsen/logrus"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Steps of an escalation.
+const (
+	NotifyDispatcher = 1
+	NotifyAdmins     = 2
+)
+
+func escalated(t ticket.Ticket, rule string, step int, since time.Time) bool {
+	for _, e := range t.Escalations {
+		if e.Rule == rule && e.Step == step && e.Since.Equal(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// due returns the steps of rule that are due for the ticket at now and did
+// not fire yet in its current idle period, along with the start of that
+// period.
+func due(t ticket.Ticket, rule Rule, now time.Time) (time.Time, []int) {
+	if !rule.Matches(t) {
+		return time.Time{}, nil
+	}
+	since := rule.IdleSince(t)
+	if since.IsZero() {
+		return since, nil
+	}
+	var steps []int
+	for step := NotifyDispatcher; step <= NotifyAdmins; step++ {
+		if now.Sub(since) >= time.Duration(step)*time.Duration(rule.After) && !escalated(t, rule.Name, step, since) {
+			steps = append(steps, step)
+		}
+	}
+	return since, steps
+}
+
+// recipients returns the engineers a step notifies: the dispatcher, or the
+// admins for the last step and when nobody is on dispatch.
+func recipients(step int, dispatcher user.User, admins []user.User) []user.User {
+	if step == NotifyAdmins || dispatcher.ID == "" {
+		return admins
+	}
+	return []user.User{dispatcher}
+}
+
+// Evaluate fires the steps of the rules that are due at now. Every step is
+// published as a ticket.escalated event naming the engineers to notify and
+// recorded on the ticket, so that it fires only once per idle period.
+func Evaluate(s *mgo.Session, rules []Rule, now time.Time) error {
+	session := s.Copy()
+	defer session.Close()
+	ctx := history.WithActor(context.Background(), "escalation")
+	c := store.Tickets(session)
+
+	var tickets []ticket.Ticket
+	err := c.Find(store.Open()).All(&tickets)
+	if err != nil {
+		return err
+	}
+	users := store.Users(session)
+	var dispatcher user.User
+	if err = users.Find(bson.M{"current": true}).One(&dispatcher); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	var admins []user.User
+	if err = users.Find(store.Live(bson.M{"admin": true})).All(&admins); err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		for _, rule := range rules {
+			since, steps := due(t, rule, now)
+			idle := now.Sub(since)
+			for _, step := range steps {
+				notify := recipients(step, dispatcher, admins)
+				e := ticket.Escalation{Rule: rule.Name, Step: step, Since: since, Time: now}
+				for _, u := range notify {
+					e.Notified = append(e.Notified, u.ID)
+				}
+				if err = c.Update(bson.M{"number": t.Number}, bson.M{"$push": bson.M{"escalations": e}, "$inc": bson.M{"version": 1}}); err != nil {
+					logs.Component("escalation").WithFields(log.Fields{"rule": rule.Name, "number": t.Number}).Error("Can't record escalation: ", err)
+					continue
+				}
+				before := t.Escalations
+				t.Escalations = append(t.Escalations, e)
+				tc := t
+				history.Record(ctx, session, t.Number, history.Updated,
+					[]ticket.Change{{Field: "escalations", From: before, To: tc.Escalations}}, &tc)
+				events.Publish(events.Event{
+					Type:   events.TicketEscalated,
+					Ticket: &tc,
+					Reason: fmt.Sprintf("%s (idle for %s)", rule.Name, idle.Truncate(time.Minute)),
+					Notify: notify,
+				})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package escalation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+	user "github.com/microservices/api/users"
+)
+
+func TestDue(t *testing.T) {
+	since := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	rule := Rule{Name: "sev1", Sev: "1", Since: SinceModified, After: Duration(15 * time.Minute)}
+	fired := func(steps ...int) []ticket.Escalation {
+		var list []ticket.Escalation
+		for _, s := range steps {
+			list = append(list, ticket.Escalation{Rule: rule.Name, Step: s, Since: since})
+		}
+		return list
+	}
+	tests := []struct {
+		name        string
+		sev         string
+		modified    time.Time
+		escalations []ticket.Escalation
+		idle        time.Duration
+		want        []int
+	}{
+		{name: "not idle long enough", sev: "1", modified: since, idle: 14 * time.Minute},
+		{name: "dispatcher", sev: "1", modified: since, idle: 15 * time.Minute, want: []int{NotifyDispatcher}},
+		{name: "both steps at once", sev: "1", modified: since, idle: time.Hour, want: []int{NotifyDispatcher, NotifyAdmins}},
+		{name: "dispatcher already notified", sev: "1", modified: since, escalations: fired(NotifyDispatcher), idle: 20 * time.Minute},
+		{name: "admins after the dispatcher", sev: "1", modified: since, escalations: fired(NotifyDispatcher), idle: 30 * time.Minute, want: []int{NotifyAdmins}},
+		{name: "all fired", sev: "1", modified: since, escalations: fired(NotifyDispatcher, NotifyAdmins), idle: time.Hour},
+		{
+			name: "new idle period", sev: "1", modified: since, idle: 20 * time.Minute, want: []int{NotifyDispatcher},
+			escalations: []ticket.Escalation{{Rule: rule.Name, Step: NotifyDispatcher, Since: since.Add(-time.Hour)}},
+		},
+		{
+			name: "fired by another rule", sev: "1", modified: since, idle: 20 * time.Minute, want: []int{NotifyDispatcher},
+			escalations: []ticket.Escalation{{Rule: "other", Step: NotifyDispatcher, Since: since}},
+		},
+		{name: "other severity", sev: "2", modified: since, idle: time.Hour},
+		{name: "never modified", sev: "1", idle: time.Hour},
+	}
+	for _, tt := range tests {
+		tk := ticket.Ticket{Number: "T1", Sev: tt.sev, ISOLastModified: tt.modified, Escalations: tt.escalations}
+		_, got := due(tk, rule, since.Add(tt.idle))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: due = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	dispatcher := user.User{ID: "jdoe"}
+	admins := []user.User{{ID: "root"}, {ID: "boss"}}
+	tests := []struct {
+		name       string
+		step       int
+		dispatcher user.User
+		want       []user.User
+	}{
+		{"dispatcher", NotifyDispatcher, dispatcher, []user.User{dispatcher}},
+		{"nobody on dispatch", NotifyDispatcher, user.User{}, admins},
+		{"admins", NotifyAdmins, dispatcher, admins},
+	}
+	for _, tt := range tests {
+		if got := recipients(tt.step, tt.dispatcher, admins); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: recipients = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
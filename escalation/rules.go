@@ -0,0 +1,96 @@
+package escalation
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+)
+
+// Clocks an idle period can be measured from.
+const (
+	// SinceModified measures from the last modification of the ticket.
+	SinceModified = "modified"
+	// SinceLog measures from the latest log entry (or the opening of the
+	// ticket when it has no log).
+	SinceLog = "log"
+)
+
+// Duration is a time.Duration written as "15m" or "4h" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule escalates the open tickets of a severity that stayed idle for too
+// long: after After the dispatcher is notified, after twice After the admins.
+type Rule struct {
+	Name string `json:"name"`
+	Sev  string `json:"sev"`
+	// State restricts the rule to the tickets in that state, e.g. "Queued".
+	State string   `json:"state"`
+	Since string   `json:"since"`
+	After Duration `json:"after"`
+}
+
+// DefaultRules returns the rules used when no rule is configured. They are
+// built on demand, as the queued state is only known once the
+// configuration is loaded.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "Sev1 queued for more than 15m", Sev: "1", State: store.Queued(), Since: SinceModified, After: Duration(15 * time.Minute)},
+		{Name: "Sev2 without a log entry for 4h", Sev: "2", Since: SinceLog, After: Duration(4 * time.Hour)},
+	}
+}
+
+// ParseRules reads a JSON array of rules.
+func ParseRules(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if r.Name == "" || r.After <= 0 {
+			return nil, fmt.Errorf("escalation: rule %q needs a name and a positive after", r.Name)
+		}
+		if r.Since != SinceModified && r.Since != SinceLog {
+			return nil, fmt.Errorf("escalation: rule %q: since must be %q or %q", r.Name, SinceModified, SinceLog)
+		}
+	}
+	return rules, nil
+}
+
+// Matches reports whether the rule applies to the ticket.
+func (r Rule) Matches(t ticket.Ticket) bool {
+	if ticket.SevLevel(r.Sev) != ticket.SevLevel(t.Sev) {
+		return false
+	}
+	return r.State == "" || r.State == t.State
+}
+
+// IdleSince returns the moment the idle period of the ticket started.
+func (r Rule) IdleSince(t ticket.Ticket) time.Time {
+	if r.Since == SinceLog {
+		since := t.ISOOpened
+		for _, l := range t.Logs {
+			if l.ISODate.After(since) {
+				since = l.ISODate
+			}
+		}
+		return since
+	}
+	return t.ISOLastModified
+}
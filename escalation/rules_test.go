@@ -0,0 +1,85 @@
+package escalation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		data string
+		want []Rule
+		err  bool
+	}{
+		{data: `[]`, want: []Rule{}},
+		{
+			data: `[{"name": "sev1", "sev": "1", "state": "Queued", "since": "modified", "after": "15m"},
+				{"name": "sev2", "sev": "Sev 2", "since": "log", "after": "4h"}]`,
+			want: []Rule{
+				{Name: "sev1", Sev: "1", State: "Queued", Since: SinceModified, After: Duration(15 * time.Minute)},
+				{Name: "sev2", Sev: "Sev 2", Since: SinceLog, After: Duration(4 * time.Hour)},
+			},
+		},
+		{data: `[{"sev": "1", "since": "modified", "after": "15m"}]`, err: true},
+		{data: `[{"name": "sev1", "since": "modified"}]`, err: true},
+		{data: `[{"name": "sev1", "since": "modified", "after": "-1m"}]`, err: true},
+		{data: `[{"name": "sev1", "since": "opened", "after": "15m"}]`, err: true},
+		{data: `[{"name": "sev1", "after": "15m"}]`, err: true},
+		{data: `[{"name": "sev1", "since": "log", "after": "soon"}]`, err: true},
+		{data: `{"name": "sev1"}`, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRules([]byte(tt.data))
+		if (err != nil) != tt.err {
+			t.Errorf("ParseRules(%s) error %v, want error %v", tt.data, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRules(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		t    ticket.Ticket
+		want bool
+	}{
+		{Rule{Sev: "1"}, ticket.Ticket{Sev: "1", State: "Open"}, true},
+		{Rule{Sev: "1"}, ticket.Ticket{Sev: "Sev 1", State: "Open"}, true},
+		{Rule{Sev: "1"}, ticket.Ticket{Sev: "2", State: "Open"}, false},
+		{Rule{Sev: "1", State: "Queued"}, ticket.Ticket{Sev: "1", State: "Queued"}, true},
+		{Rule{Sev: "1", State: "Queued"}, ticket.Ticket{Sev: "1", State: "Open"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.t); got != tt.want {
+			t.Errorf("%+v.Matches(sev %q, state %q) = %v, want %v", tt.rule, tt.t.Sev, tt.t.State, got, tt.want)
+		}
+	}
+}
+
+func TestIdleSince(t *testing.T) {
+	opened := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	modified := opened.Add(3 * time.Hour)
+	logged := opened.Add(2 * time.Hour)
+	tests := []struct {
+		name  string
+		since string
+		logs  []ticket.TicketLog
+		want  time.Time
+	}{
+		{"modified", SinceModified, nil, modified},
+		{"no log", SinceLog, nil, opened},
+		{"latest log", SinceLog, []ticket.TicketLog{{ISODate: logged}, {ISODate: opened.Add(time.Hour)}}, logged},
+	}
+	for _, tt := range tests {
+		tk := ticket.Ticket{ISOOpened: opened, ISOLastModified: modified, Logs: tt.logs}
+		if got := (Rule{Since: tt.since}).IdleSince(tk); !got.Equal(tt.want) {
+			t.Errorf("%s: IdleSince = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microservices/api/logs"
+	ticket "github.com/microservices/api/tickets"
+	user "github.com/microservices/api/users"
+)
+
+// Event types published by the API.
+const (
+	TicketCreated      = "ticket.created"
+	TicketUpdated      = "ticket.updated"
+	TicketDeleted      = "ticket.deleted"
+	TicketRestored     = "ticket.restored"
+	TicketStateChanged = "ticket.state_changed"
+	TicketOwnerChanged = "ticket.owner_changed"
+	RotationAdvanced   = "rotation.advanced"
+	DefectLinked       = "defect.linked"
+	TicketEscalated    = "ticket.escalated"
+)
+
+// Reset is the type of the event starting a subscription from an event id
+// the bus cannot resume from: the events since were lost, and a client
+// should reload its state. It is not published, so it is not in Types.
+const Reset = "stream.reset"
+
+// Types are the types of the events published by the API.
+var Types = []string{
+	TicketCreated,
+	TicketUpdated,
+	TicketDeleted,
+	TicketRestored,
+	TicketStateChanged,
+	TicketOwnerChanged,
+	RotationAdvanced,
+	DefectLinked,
+	TicketEscalated,
+}
+
+// Known reports whether t is the type of events published by the API.
+func Known(t string) bool {
+	for _, known := range Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened to a ticket or to the rotation.
+type Event struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Time     time.Time       `json:"time"`
+	Ticket   *ticket.Ticket  `json:"ticket,omitempty"`
+	User     *user.User      `json:"user,omitempty"`
+	Role     string          `json:"role,omitempty"`
+	Defect   string          `json:"defect,omitempty"`
+	Previous string          `json:"previous,omitempty"`
+	Reason   string          `json:"reason,omitempty"`
+	Changes  []ticket.Change `json:"changes,omitempty"`
+	Notify   []user.User     `json:"notify,omitempty"`
+
+	// seq is the rank of the event on its bus.
+	seq uint64
+}
+
+// HistorySize is the number of recent events a bus keeps for late subscribers.
+const HistorySize = 1024
+
+// Bus fans events out to its subscribers. The event ids are the epoch of
+// the bus, set when it is created, and a sequence number: an id from before
+// a restart is not mistaken for a recent one.
+type Bus struct {
+	mu    sync.Mutex
+	epoch string
+	last  uint64
+	// subs maps the channel of every subscription to whether it is a
+	// client stream.
+	subs    map[chan Event]bool
+	history []Event
+	hooks   []func(Event)
+	closed  bool
+	// streamsClosed is set once the client streams are ended.
+	streamsClosed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:  make(map[chan Event]bool),
+	}
+}
+
+// Default is the bus the handlers publish to.
+var Default = NewBus()
+
+// Hook registers f to be called with every event published from now on. The
+// hooks are called by Publish, in the goroutine of the publisher, so that a
+// consumer that cannot miss an event can store it before Publish returns.
+func (b *Bus) Hook(f func(Event)) {
+	b.mu.Lock()
+	b.hooks = append(b.hooks, f)
+	b.mu.Unlock()
+}
+
+// Publish stamps e with the next event id and the current time (unless set),
+// calls the hooks and hands it to every subscriber. Publishing never blocks
+// on a subscriber: one whose buffer is full is unsubscribed, its channel
+// closed, and must subscribe again with SubscribeSince to catch up.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	b.last++
+	e.seq = b.last
+	e.ID = b.id(b.last)
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if len(b.history) == HistorySize {
+		b.history = b.history[1:]
+	}
+	b.history = append(b.history, e)
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			logs.Component("events").WithField("event", e.ID).Warn("Subscriber is full, disconnected it")
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(e)
+	}
+	return e
+}
+
+// Subscribe returns a channel receiving the events published from now on and
+// a function to cancel the subscription. The channel is closed when the
+// subscription is cancelled, falls behind or the bus is closed.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(buffer, false)
+}
+
+func (b *Bus) subscribe(buffer int, stream bool) (chan Event, func()) {
+	ch := make(chan Event, buffer)
+	if b.closed || (stream && b.streamsClosed) {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = stream
+	return ch, func() {
+		b.mu.Lock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// SubscribeSince subscribes like Subscribe and also returns the kept events
+// published after the event with id last, so that no event is lost between
+// the two. An empty last starts from now. When the events after last are
+// not all kept, or last is unknown to the bus (e.g. the service restarted
+// since), a single Reset event is returned: the subscription starts from
+// now and the subscriber must reload.
+func (b *Bus) SubscribeSince(last string, buffer int) ([]Event, <-chan Event, func()) {
+	return b.subscribeSince(last, buffer, false)
+}
+
+// StreamSince subscribes a client stream like SubscribeSince. Unlike the
+// in-process subscribers, the client streams are ended by CloseStreams.
+func (b *Bus) StreamSince(last string, buffer int) ([]Event, <-chan Event, func()) {
+	return b.subscribeSince(last, buffer, true)
+}
+
+func (b *Bus) subscribeSince(last string, buffer int, stream bool) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var missed []Event
+	if last != "" {
+		if after, ok := b.resume(last); ok {
+			for _, e := range b.history {
+				if e.seq > after {
+					missed = append(missed, e)
+				}
+			}
+		} else {
+			missed = []Event{{ID: b.id(b.last), Type: Reset, Time: time.Now().UTC(), seq: b.last}}
+		}
+	}
+	ch, cancel := b.subscribe(buffer, stream)
+	return missed, ch, cancel
+}
+
+// resume returns the sequence number of the event id last when the bus
+// kept every event published after it.
+func (b *Bus) resume(last string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(last, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > b.last {
+		return 0, false
+	}
+	if len(b.history) > 0 && b.history[0].seq > n+1 {
+		return 0, false
+	}
+	return n, true
+}
+
+func (b *Bus) id(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// CloseStreams ends the client streams, now and to come, so that a server
+// shutting down is not kept waiting by them. The in-process subscribers
+// keep receiving the events.
+func (b *Bus) CloseStreams() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.streamsClosed = true
+	for ch, stream := range b.subs {
+		if stream {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription; the subscribers see their channel closed,
+// as do the ones subscribing later.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Closed reports whether the bus is closed, telling a subscriber whose
+// channel is closed whether to subscribe again.
+func (b *Bus) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Publish publishes e on the default bus.
+func Publish(e Event) Event {
+	return Default.Publish(e)
+}
+
+// Subscribe subscribes to the default bus.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	return Default.Subscribe(buffer)
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func types(events []Event) []string {
+	var list []string
+	for _, e := range events {
+		list = append(list, e.Type)
+	}
+	return list
+}
+
+func TestSubscribeSince(t *testing.T) {
+	other := NewBus()
+	other.epoch = "other"
+	stale := other.Publish(Event{Type: TicketCreated}).ID
+
+	tests := []struct {
+		name string
+		last func(b *Bus, ids []string) string
+		want []string
+	}{
+		{
+			name: "empty id starts from now",
+			last: func(b *Bus, ids []string) string { return "" },
+		},
+		{
+			name: "recognised id replays the events after it",
+			last: func(b *Bus, ids []string) string { return ids[0] },
+			want: []string{TicketUpdated, TicketDeleted},
+		},
+		{
+			name: "latest id replays nothing",
+			last: func(b *Bus, ids []string) string { return ids[2] },
+		},
+		{
+			name: "id of another epoch resets",
+			last: func(b *Bus, ids []string) string { return stale },
+			want: []string{Reset},
+		},
+		{
+			name: "unknown id resets",
+			last: func(b *Bus, ids []string) string { return b.epoch + "-99" },
+			want: []string{Reset},
+		},
+		{
+			name: "malformed id resets",
+			last: func(b *Bus, ids []string) string { return "42" },
+			want: []string{Reset},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+			var ids []string
+			for _, typ := range []string{TicketCreated, TicketUpdated, TicketDeleted} {
+				ids = append(ids, b.Publish(Event{Type: typ}).ID)
+			}
+			missed, ch, cancel := b.SubscribeSince(tt.last(b, ids), 4)
+			defer cancel()
+			if got := types(missed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missed %q, want %q", got, tt.want)
+			}
+			if len(missed) > 0 && missed[len(missed)-1].ID != ids[2] {
+				t.Errorf("last missed event %s, want the id of the latest event %s", missed[len(missed)-1].ID, ids[2])
+			}
+			b.Publish(Event{Type: TicketRestored})
+			if e := <-ch; e.Type != TicketRestored {
+				t.Errorf("received %s, want %s", e.Type, TicketRestored)
+			}
+		})
+	}
+}
+
+func TestSubscribeSinceEvicted(t *testing.T) {
+	b := NewBus()
+	first := b.Publish(Event{Type: TicketCreated}).ID
+	for i := 0; i < HistorySize+1; i++ {
+		b.Publish(Event{Type: TicketUpdated})
+	}
+	missed, _, cancel := b.SubscribeSince(first, 1)
+	defer cancel()
+	if got := types(missed); !reflect.DeepEqual(got, []string{Reset}) {
+		t.Errorf("missed %q, want a reset once the events after the id are dropped", got)
+	}
+}
+
+func TestPublishDisconnectsFullSubscriber(t *testing.T) {
+	b := NewBus()
+	slow, _ := b.Subscribe(1)
+	fast, cancel := b.Subscribe(4)
+	defer cancel()
+	b.Publish(Event{Type: TicketCreated})
+	b.Publish(Event{Type: TicketUpdated})
+
+	if e, ok := <-slow; !ok || e.Type != TicketCreated {
+		t.Fatalf("slow subscriber got %v %v, want the first event", e.Type, ok)
+	}
+	if _, ok := <-slow; ok {
+		t.Error("the full subscriber is still subscribed")
+	}
+	if got := len(fast); got != 2 {
+		t.Errorf("the other subscriber got %d events, want 2", got)
+	}
+}
+
+func TestCloseStreams(t *testing.T) {
+	b := NewBus()
+	_, stream, _ := b.StreamSince("", 1)
+	sub, cancel := b.Subscribe(1)
+	defer cancel()
+	b.CloseStreams()
+	if _, ok := <-stream; ok {
+		t.Error("the stream is still open")
+	}
+	b.Publish(Event{Type: TicketCreated})
+	if _, ok := <-sub; !ok {
+		t.Error("the in-process subscriber was closed with the streams")
+	}
+	_, later, _ := b.StreamSince("", 1)
+	if _, ok := <-later; ok {
+		t.Error("a stream opened after CloseStreams is open")
+	}
+}
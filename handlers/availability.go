@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/store"
+	u "github.com/microservices/api/users"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func userAvailability(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		uid := vars["uid"]
+
+		c := store.Availability(session)
+		var records []u.Availability
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(bson.M{"user_id": uid}).Sort("from").All(&records) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		respBody, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func addAvailability(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		uid := vars["uid"]
+
+		var record u.Availability
+		err := decodeJSON(r, &record)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if !record.From.Before(record.To) {
+			apierror.Write(w, r, apierror.BadRequest("Availability must end after it starts"))
+			return
+		}
+		users := store.Users(session)
+		var n int
+		err = mongoOp(r.Context(), users, "count", func() (err error) {
+			n, err = users.Find(store.Live(bson.M{"id": uid})).Count()
+			return err
+		})
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		if n == 0 {
+			apierror.Write(w, r, apierror.NotFound("User not found"))
+			return
+		}
+		record.Id = bson.NewObjectId()
+		record.UserID = uid
+
+		c := store.Availability(session)
+		err = mongoOp(r.Context(), c, "insert", func() error { return c.Insert(record) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/availability/"+record.Id.Hex())
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func deleteAvailability(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		id := vars["id"]
+		if !bson.IsObjectIdHex(id) {
+			apierror.Write(w, r, apierror.NotFound("Availability not found"))
+			return
+		}
+
+		c := store.Availability(session)
+		err := mongoOp(r.Context(), c, "remove", func() error { return c.RemoveId(bson.ObjectIdHex(id)) })
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Availability not found"))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/chatops"
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/store"
+	u "github.com/microservices/api/users"
+	log "github.com/sirupsen/logrus"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// maxCommandBody bounds the size of a slash command request.
+const maxCommandBody = 1 << 20
+
+// chatMention matches the escaped user mentions Slack sends, <@U123|name>.
+var chatMention = regexp.MustCompile(`^<@([A-Z0-9]+)(\|[^>]*)?>$`)
+
+const dispatchHelp = "Usage: `/dispatch who`, `/dispatch next`, `/dispatch away <user>`, `/ticket <number>`, `/workload`"
+
+// chatCommand implements the Slack slash-command protocol for the rotation
+// and ticket lookups. Every request must be signed with the signing secret.
+func chatCommand(s *mgo.Session, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.SlackSigningSecret == "" {
+			apierror.Write(w, r, apierror.NotFound("Chat commands are not configured"))
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxCommandBody))
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Incorrect body"))
+			return
+		}
+		if err = chatops.VerifySlack(cfg.SlackSigningSecret, r.Header, body, time.Now()); err != nil {
+			reqLog(r).Error("Rejected chat command: ", err)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid signature"))
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Incorrect body"))
+			return
+		}
+
+		session := s.Copy()
+		defer session.Close()
+
+		args := strings.Fields(form.Get("text"))
+		var resp chatops.Response
+		switch form.Get("command") {
+		case "/dispatch":
+			resp = dispatchCommand(r.Context(), session, args)
+		case "/ticket":
+			resp = ticketCommand(r.Context(), session, args)
+		case "/workload":
+			resp = workloadCommand(r.Context(), session)
+		default:
+			resp = chatops.Ephemeral(dispatchHelp)
+		}
+
+		respBody, err := json.Marshal(resp)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func dispatchCommand(ctx context.Context, session *mgo.Session, args []string) chatops.Response {
+	if len(args) == 0 {
+		return chatops.Ephemeral(dispatchHelp)
+	}
+	switch args[0] {
+	case "who":
+		user, err := rotation.Current(ctx, session, "")
+		if err != nil {
+			return chatError(err)
+		}
+		return chatops.Reply(fmt.Sprintf("*%s* (<@%s>) is on dispatch.", user.RealName, user.ID))
+	case "next":
+		user, err := rotation.Advance(ctx, session, "", time.Now())
+		if err != nil {
+			return chatError(err)
+		}
+		return chatops.Reply(fmt.Sprintf("Dispatch passed to *%s* (<@%s>).", user.RealName, user.ID))
+	case "away":
+		if len(args) != 2 {
+			return chatops.Ephemeral("Usage: `/dispatch away <user>`")
+		}
+		uid, err := resolveChatUser(ctx, session, args[1])
+		if err != nil {
+			return chatError(err)
+		}
+		user, err := rotation.Blacklist(ctx, session, uid)
+		if err != nil {
+			return chatError(err)
+		}
+		return chatops.Reply(fmt.Sprintf("*%s* (<@%s>) is away and left the rotation.", user.RealName, user.ID))
+	}
+	return chatops.Ephemeral(dispatchHelp)
+}
+
+func ticketCommand(ctx context.Context, session *mgo.Session, args []string) chatops.Response {
+	if len(args) != 1 {
+		return chatops.Ephemeral("Usage: `/ticket <number>`")
+	}
+	t, err := findTicket(ctx, session, args[0])
+	if err != nil {
+		return chatError(err)
+	}
+	text := fmt.Sprintf("*Ticket %s*: %s", t.Number, t.Abstract)
+	return chatops.Reply(text, chatops.Section(text,
+		"*Severity*\n"+t.Sev,
+		"*State*\n"+t.State,
+		"*Owner*\n"+t.Owner,
+		"*Opened*\n"+t.Opened,
+		"*Last modified*\n"+t.LastModified,
+	))
+}
+
+func workloadCommand(ctx context.Context, session *mgo.Session) chatops.Response {
+	workloads, err := loadWorkload(ctx, session)
+	if err != nil {
+		return chatError(err)
+	}
+	if len(workloads) == 0 {
+		return chatops.Reply("No open tickets.")
+	}
+	var blocks []chatops.Block
+	for _, w := range workloads {
+		owner := "Unassigned"
+		if len(w.Tickets) > 0 && w.Tickets[0].Owner != "" {
+			owner = w.Tickets[0].Owner
+		}
+		var lines []string
+		for _, t := range w.Tickets {
+			lines = append(lines, fmt.Sprintf("%s (%s)", t.Num, t.State))
+		}
+		blocks = append(blocks, chatops.Section(fmt.Sprintf("*%s*: %d open\n%s", owner, len(w.Tickets), strings.Join(lines, "\n"))))
+	}
+	return chatops.Reply("Workload", blocks...)
+}
+
+// resolveChatUser finds the id of the user named by a chat mention, an id or
+// a user name.
+func resolveChatUser(ctx context.Context, session *mgo.Session, arg string) (string, error) {
+	if m := chatMention.FindStringSubmatch(arg); m != nil {
+		return m[1], nil
+	}
+	arg = strings.TrimPrefix(arg, "@")
+	var user u.User
+	c := store.Users(session)
+	err := mongoOp(ctx, c, "find", func() error {
+		return c.Find(store.Live(bson.M{"$or": []bson.M{bson.M{"id": arg}, bson.M{"name": arg}}})).One(&user)
+	})
+	return user.ID, err
+}
+
+func chatError(err error) chatops.Response {
+	switch err {
+	case mgo.ErrNotFound:
+		return chatops.Ephemeral("Not found.")
+	case rotation.ErrNoEngineer:
+		return chatops.Ephemeral("No engineer is available.")
+	case rotation.ErrCurrentUser:
+		return chatops.Ephemeral("This user is on dispatch. Run `/dispatch next` first.")
+	}
+	log.Error("Failed chat command: ", err)
+	return chatops.Ephemeral("Database error.")
+}
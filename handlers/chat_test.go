@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microservices/api/chatops"
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/store/storetest"
+	ticket "github.com/microservices/api/tickets"
+	u "github.com/microservices/api/users"
+)
+
+const chatSecret = "slack-secret"
+
+// chatRequest is the slash command request Slack sends for command and
+// text, signed with secret at the time at.
+func chatRequest(secret, command, text string, at time.Time) *http.Request {
+	body := url.Values{"command": {command}, "text": {text}}.Encode()
+	r := httptest.NewRequest("POST", "/api/chat/command", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestChatCommandRejected(t *testing.T) {
+	unsigned := chatRequest(chatSecret, "/dispatch", "who", time.Now())
+	unsigned.Header.Del("X-Slack-Signature")
+	tests := []struct {
+		name   string
+		secret string
+		r      *http.Request
+		status int
+	}{
+		{"not configured", "", chatRequest(chatSecret, "/dispatch", "who", time.Now()), http.StatusNotFound},
+		{"unsigned", chatSecret, unsigned, http.StatusUnauthorized},
+		{"other secret", chatSecret, chatRequest("other", "/dispatch", "who", time.Now()), http.StatusUnauthorized},
+		{"stale", chatSecret, chatRequest(chatSecret, "/dispatch", "who", time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		chatCommand(nil, Config{SlackSigningSecret: tt.secret})(w, tt.r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestChatCommand(t *testing.T) {
+	session := storetest.Session(t)
+	for _, user := range []u.User{
+		{ID: "U100", Name: "jdoe", RealName: "John Doe", Active: true, Engineer: true, Current: true},
+		{ID: "U200", Name: "asmith", RealName: "Ann Smith", Active: true, Engineer: true},
+	} {
+		if err := store.Users(session).Insert(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Tickets(session).Insert(ticket.Ticket{Number: "T1", Abstract: "disk full", Sev: "2", State: "Open", Owner: "jdoe"}); err != nil {
+		t.Fatal(err)
+	}
+	h := chatCommand(session, Config{SlackSigningSecret: chatSecret})
+
+	tests := []struct {
+		command, text string
+		ephemeral     bool
+		reply         string
+	}{
+		{"/dispatch", "", true, dispatchHelp},
+		{"/dispatch", "fly", true, dispatchHelp},
+		{"/dispatch", "who", false, "*John Doe* (<@U100>) is on dispatch."},
+		{"/dispatch", "away <@U100|jdoe>", true, "This user is on dispatch. Run `/dispatch next` first."},
+		{"/dispatch", "away", true, "Usage: `/dispatch away <user>`"},
+		{"/dispatch", "next", false, "Dispatch passed to *Ann Smith* (<@U200>)."},
+		{"/dispatch", "away @jdoe", false, "*John Doe* (<@U100>) is away and left the rotation."},
+		{"/dispatch", "away nobody", true, "Not found."},
+		{"/dispatch", "away <@U200|asmith>", true, "This user is on dispatch. Run `/dispatch next` first."},
+		{"/ticket", "T1", false, "*Ticket T1*: disk full"},
+		{"/ticket", "T9", true, "Not found."},
+		{"/ticket", "T1 T2", true, "Usage: `/ticket <number>`"},
+		{"/workload", "", false, "Workload"},
+		{"/weather", "today", true, dispatchHelp},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, chatRequest(chatSecret, tt.command, tt.text, time.Now()))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", tt.command, tt.text, w.Code, w.Body)
+		}
+		var resp chatops.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text != tt.reply || (resp.ResponseType == "ephemeral") != tt.ephemeral {
+			t.Errorf("%s %s: %s reply %q, want %q", tt.command, tt.text, resp.ResponseType, resp.Text, tt.reply)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"regexp"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
 	defect "github.com/microservices/api/defects"
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -21,23 +26,23 @@ func addDefect(s *mgo.Session) http.HandlerFunc {
 			err error
 		)
 		var d defect.DefectOutput
-		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&d)
+		err = decodeJSON(r, &d)
 		if err != nil {
-			ErrorWithJSON(w, "Incorrect body", http.StatusBadRequest)
+			apierror.Write(w, r, err)
 			return
 		}
-		c := session.DB("info").C("defect")
-		err = c.Insert(d)
+		c := store.Defects(session)
+		err = mongoOp(r.Context(), c, "insert", func() error { return c.Insert(d) })
 		if err != nil {
 			if mgo.IsDup(err) {
-				ErrorWithJSON(w, "Defect is already exists", http.StatusBadRequest)
+				apierror.Write(w, r, apierror.Conflict("Defect is already exists"))
 				return
 			}
 
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
+		events.Publish(events.Event{Type: events.DefectLinked, Ticket: &ticket.Ticket{Number: d.Number}, Defect: d.Defects})
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Location", r.URL.Path+"/"+d.Defects)
@@ -49,19 +54,26 @@ func getDefect(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("info").C("defect")
+		c := store.Defects(session)
 		vars := mux.Vars(r)
 		id := vars["defect"]
 		var d defect.DefectOutput
-		err := c.Find(bson.M{"defects": id}).One(&d)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(bson.M{"defects": id}).One(&d) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			return
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Defect not found"))
+				return
+			}
 		}
 
 		respBody, err := json.MarshalIndent(d, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -70,20 +82,20 @@ func searchDefects(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 		re := regexp.MustCompile(`https://sdp.web.att.com\S{50,83}(/|=)[\d]{6}`)
 		defectNum := regexp.MustCompile(`[\d]{6}`)
 		var defects []defect.Defect
-		pipe := c.Pipe([]bson.M{{"$match": bson.M{"$and": []bson.M{bson.M{"state": bson.M{"$ne": "Cancel"}}, bson.M{"state": bson.M{"$ne": "Closed"}}}}},
+		pipe := c.Pipe([]bson.M{{"$match": store.Open()},
 			{"$unwind": "$logs"},
 			{"$match": bson.M{"logs.info": bson.M{"$regex": bson.RegEx{`.*Workitem.*`, "sim"}}}},
 			{"$group": bson.M{"_id": "$number", "info": bson.M{"$push": "$logs.info"}}}})
-		err := pipe.All(&defects)
+		err := mongoOp(r.Context(), c, "aggregate", func() error { return pipe.All(&defects) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			log.Println("Failed get report: ", err)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
+		_, span := tracing.Start(r.Context(), "extract defects", attribute.Int("tickets", len(defects)))
 		var resp []defect.DefectOutput
 		// goroutine to increase spead
 		for _, d := range defects {
@@ -101,9 +113,12 @@ func searchDefects(s *mgo.Session) http.HandlerFunc {
 		}
 
 		resp = removeDuplicatesUnordered(resp)
+		span.SetAttributes(attribute.Int("defects", len(resp)))
+		span.End()
 		respBody, err := json.MarshalIndent(resp, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
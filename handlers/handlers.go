@@ -1,38 +1,131 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/config"
+	"github.com/microservices/api/metrics"
+	"github.com/microservices/api/scheduler"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
+	"github.com/microservices/api/validate"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/mgo.v2"
 )
 
-func ErrorWithJSON(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	fmt.Fprintf(w, "{\"message\": %q}", message)
-}
-
 func ResponseWithJSON(w http.ResponseWriter, json []byte, code int) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
 	w.Write(json)
 }
 
-func Router(session *mgo.Session) *mux.Router {
+// Config holds the settings the handlers need besides the Mongo session.
+type Config struct {
+	// ShiftLength is the duration of one dispatch turn in the rotation.
+	ShiftLength time.Duration
+	// SlackSigningSecret verifies the slash commands; they are disabled
+	// when it is empty.
+	SlackSigningSecret string
+	// Scheduler runs the periodic jobs of the service.
+	Scheduler *scheduler.Scheduler
+	// Ready reports whether the startup work needed to serve (the indexes)
+	// is done.
+	Ready func() bool
+	// Auth tells how the API callers are authenticated.
+	Auth config.Auth
+	// CORS lists the browser origins allowed to call the API.
+	CORS config.CORS
+	// Features turns the optional routes on and off.
+	Features config.Features
+	// Normalizer computes the ISO timestamps of the tickets received; the
+	// default layouts in UTC are used when it is nil.
+	Normalizer ticket.Normalizer
+}
+
+// decodeJSON decodes the JSON body of r into v and validates it. Unknown
+// fields and trailing data are rejected. The error returned is the API error
+// to answer with.
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errors.New("trailing data after the JSON value")
+	}
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr):
+			return apierror.Invalid(apierror.FieldError{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()})
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+			return apierror.Invalid(apierror.FieldError{Field: field, Message: "is not a known field"})
+		case err == io.EOF:
+			return apierror.BadRequest("Incorrect body: empty")
+		}
+		return apierror.BadRequest("Incorrect body: " + err.Error())
+	}
+	if fields := validate.Struct(v); len(fields) > 0 {
+		return apierror.Invalid(fields...)
+	}
+	return nil
+}
+
+// mongoOp runs op, an operation on the collection c, in a child span of ctx
+// and records its latency.
+func mongoOp(ctx context.Context, c *mgo.Collection, operation string, op func() error) error {
+	return tracing.MongoOp(ctx, c, operation, op)
+}
+
+func Router(session *mgo.Session, cfg Config) *mux.Router {
+	if cfg.Normalizer == nil {
+		cfg.Normalizer, _ = ticket.NewNormalizer("UTC", nil)
+	}
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.NotFound("Route not found"))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(apierror.CodeMethodNotAllowed, "Method not allowed"))
+	})
+	id := newIdentity(cfg.Auth)
+	r.Use(tracing.Middleware, metrics.Middleware, accessLog(id), cors(cfg.CORS), authenticate(id))
+
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.PathPrefix("/").Methods("OPTIONS").HandlerFunc(preflight)
+	}
+
+	if cfg.Features.Metrics {
+		r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	}
+
+	r.HandleFunc("/healthz", healthz()).Methods("GET")
+	r.HandleFunc("/readyz", readyz(ping(session), cfg.Ready)).Methods("GET")
+	r.HandleFunc("/version", versionInfo()).Methods("GET")
 
 	r.HandleFunc("/api/tickets", allTickets(session)).Methods("GET")
 	r.HandleFunc("/api/ticket/{number}", ticketByNumber(session)).Methods("GET")
+	r.HandleFunc("/api/ticket/{number}/history", ticketHistory(session)).Methods("GET")
 	r.HandleFunc("/api/tickets/active", activeTickets(session)).Methods("GET")
 	r.HandleFunc("/api/tickets/queued", queuedTickets(session)).Methods("GET")
 	r.HandleFunc("/api/tickets/report/{year}/{week}", reportTickets(session)).Methods("GET")
 	r.HandleFunc("/api/tickets/reportclosed/{year}/{week}", reportClosedTickets(session)).Methods("GET")
 	r.HandleFunc("/api/backlog/{date}", reportBacklogTickets(session)).Methods("GET")
-	r.HandleFunc("/api/ticket", addTicket(session)).Methods("POST")
-	r.HandleFunc("/api/ticket/{number}", updateTicket(session)).Methods("PUT")
+	r.HandleFunc("/api/ticket", addTicket(session, cfg)).Methods("POST")
+	r.HandleFunc("/api/tickets/bulk", bulkTickets(session, cfg)).Methods("POST")
+	r.HandleFunc("/api/ticket/{number}", updateTicket(session, cfg)).Methods("PUT")
 	r.HandleFunc("/api/tickets/{number}", deleteTicket(session)).Methods("DELETE")
+	r.HandleFunc("/api/tickets/{number}/restore", restoreTicket(session)).Methods("POST")
+	r.HandleFunc("/api/ticket/{number}/assign", assignTicket(session)).Methods("POST")
 
 	//users
 	r.HandleFunc("/api/workload", workload(session)).Methods("GET")
@@ -42,17 +135,54 @@ func Router(session *mgo.Session) *mux.Router {
 	r.HandleFunc("/api/users/admins", adminsUsers(session)).Methods("GET")
 	r.HandleFunc("/api/users/current", currentUser(session)).Methods("GET")
 	r.HandleFunc("/api/users/next", nextUser(session)).Methods("GET")
-	r.HandleFunc("/api/user/:uid", getUser(session)).Methods("GET")
-	r.HandleFunc("/api/attuser/:attuid", getAttUser(session)).Methods("GET")
-	r.HandleFunc("/api/user/blacklist/:uid", blacklistUser(session)).Methods("GET")
-	r.HandleFunc("/api/user/whitelist/:uid", whitelistUser(session)).Methods("GET")
-	r.HandleFunc("/api/user/isadmin/:uid", isAdmin(session)).Methods("GET")
+	r.HandleFunc("/api/user/{uid}", getUser(session)).Methods("GET")
+	r.HandleFunc("/api/attuser/{attuid}", getAttUser(session)).Methods("GET")
+	r.HandleFunc("/api/user/blacklist/{uid}", blacklistUser(session)).Methods("GET")
+	r.HandleFunc("/api/user/whitelist/{uid}", whitelistUser(session)).Methods("GET")
+	r.HandleFunc("/api/user/isadmin/{uid}", isAdmin(session)).Methods("GET")
 	r.HandleFunc("/api/user", addUser(session)).Methods("POST")
-	r.HandleFunc("/api/user/:uid", updateUser(session)).Methods("PUT")
-	r.HandleFunc("/api/user/:uid", deleteUser(session)).Methods("DELETE")
+	r.HandleFunc("/api/user/{uid}", updateUser(session)).Methods("PUT")
+	r.HandleFunc("/api/user/{uid}", deleteUser(session)).Methods("DELETE")
+	r.HandleFunc("/api/user/{uid}/restore", restoreUser(session)).Methods("POST")
+
+	//availability
+	r.HandleFunc("/api/user/{uid}/availability", userAvailability(session)).Methods("GET")
+	r.HandleFunc("/api/user/{uid}/availability", addAvailability(session)).Methods("POST")
+	r.HandleFunc("/api/availability/{id}", deleteAvailability(session)).Methods("DELETE")
+
+	//schedule
+	r.HandleFunc("/api/schedule.ics", scheduleCalendar(session, cfg)).Methods("GET")
+	r.HandleFunc("/api/user/{uid}/schedule.ics", userScheduleCalendar(session, cfg)).Methods("GET")
+
+	//events
+	if cfg.Features.Events {
+		r.HandleFunc("/api/events", streamEvents()).Methods("GET")
+	}
+
+	//chat
+	if cfg.Features.ChatOps {
+		r.HandleFunc("/api/chat/command", chatCommand(session, cfg)).Methods("POST")
+	}
+
+	//jobs
+	if cfg.Scheduler != nil {
+		r.HandleFunc("/api/jobs", allJobs(cfg)).Methods("GET")
+		r.HandleFunc("/api/jobs/{name}", jobRuns(cfg)).Methods("GET")
+		r.HandleFunc("/api/jobs/{name}", runJob(cfg)).Methods("POST")
+	}
+
+	//webhooks
+	if cfg.Features.Webhooks {
+		r.HandleFunc("/api/webhooks", allWebhooks(session)).Methods("GET")
+		r.HandleFunc("/api/webhooks", addWebhook(session)).Methods("POST")
+		r.HandleFunc("/api/webhooks/{id}", deleteWebhook(session)).Methods("DELETE")
+		r.HandleFunc("/api/webhooks/{id}/deliveries", webhookDeliveries(session)).Methods("GET")
+	}
 
 	//defects
 	r.HandleFunc("/api/defects", searchDefects(session)).Methods("GET")
+	r.HandleFunc("/api/defect", addDefect(session)).Methods("POST")
+	r.HandleFunc("/api/defect/{defect}", getDefect(session)).Methods("GET")
 	//r.HandleFunc("/api/zones", searchZones(session)).Methods("GET")
 
 	//go http.ListenAndServe("0.0.0.0:8083", prof)
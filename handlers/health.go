@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/version"
+	mgo "gopkg.in/mgo.v2"
+)
+
+type probe struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func writeProbe(w http.ResponseWriter, r *http.Request, p probe, code int) {
+	respBody, err := json.Marshal(p)
+	if err != nil {
+		reqLog(r).Error(err)
+	}
+	ResponseWithJSON(w, respBody, code)
+}
+
+// healthz tells the process is alive; it does not look at the dependencies.
+func healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, r, probe{Status: "ok"}, http.StatusOK)
+	}
+}
+
+// ping checks that Mongo answers on a copy of s.
+func ping(s *mgo.Session) func() error {
+	return func() error {
+		session := s.Copy()
+		defer session.Close()
+		return session.Ping()
+	}
+}
+
+// readyz tells the service can take traffic: Mongo answers ping and ready
+// reports the indexes have been ensured.
+func readyz(ping func() error, ready func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ping(); err != nil {
+			reqLog(r).Error("Readiness: mongo ping failed: ", err)
+			writeProbe(w, r, probe{Status: "unavailable", Reason: "mongo is unreachable"}, http.StatusServiceUnavailable)
+			return
+		}
+		if ready != nil && !ready() {
+			writeProbe(w, r, probe{Status: "unavailable", Reason: "indexes are not ensured yet"}, http.StatusServiceUnavailable)
+			return
+		}
+		writeProbe(w, r, probe{Status: "ok"}, http.StatusOK)
+	}
+}
+
+func versionInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respBody, err := json.MarshalIndent(map[string]string{
+			"commit":  version.Commit,
+			"build":   version.BuildTime,
+			"release": version.Release,
+		}, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	up := func() error { return nil }
+	down := func() error { return errors.New("no reachable servers") }
+	yes := func() bool { return true }
+	no := func() bool { return false }
+	tests := []struct {
+		name   string
+		ping   func() error
+		ready  func() bool
+		status int
+		want   probe
+	}{
+		{"ready", up, yes, http.StatusOK, probe{Status: "ok"}},
+		{"no startup work", up, nil, http.StatusOK, probe{Status: "ok"}},
+		{"indexes pending", up, no, http.StatusServiceUnavailable, probe{Status: "unavailable", Reason: "indexes are not ensured yet"}},
+		{"mongo down", down, yes, http.StatusServiceUnavailable, probe{Status: "unavailable", Reason: "mongo is unreachable"}},
+		{"mongo down and indexes pending", down, no, http.StatusServiceUnavailable, probe{Status: "unavailable", Reason: "mongo is unreachable"}},
+	}
+	for _, tt := range tests {
+		w := serve(readyz(tt.ping, tt.ready), "GET", "/readyz", "")
+		var got probe
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v: %s", tt.name, err, w.Body)
+		}
+		if w.Code != tt.status || got != tt.want {
+			t.Errorf("%s: %d %+v, want %d %+v", tt.name, w.Code, got, tt.status, tt.want)
+		}
+	}
+}
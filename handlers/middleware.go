@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/config"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/metrics"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request id, taken from the client or a proxy
+// when present and generated otherwise.
+const RequestIDHeader = "X-Request-ID"
+
+// identity tells who sent a request, following the auth mode.
+type identity struct {
+	auth    config.Auth
+	proxies []*net.IPNet
+}
+
+func newIdentity(a config.Auth) identity {
+	// The trusted proxies are checked by config.Validate.
+	proxies, _ := a.Proxies()
+	return identity{auth: a, proxies: proxies}
+}
+
+// user returns the authenticated user of the request: the user set by a
+// trusted proxy in proxy mode, or the basic auth user whose password checks
+// in basic mode. It is empty when the request is not authenticated.
+func (id identity) user(r *http.Request) string {
+	switch id.auth.Mode {
+	case config.AuthProxy:
+		if !id.trusted(r) {
+			return ""
+		}
+		for _, h := range []string{"X-Remote-User", "X-Forwarded-User"} {
+			if user := r.Header.Get(h); user != "" {
+				return user
+			}
+		}
+	case config.AuthBasic:
+		user, password, ok := r.BasicAuth()
+		want, known := id.auth.Users[user]
+		if ok && known && subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1 {
+			return user
+		}
+	}
+	return ""
+}
+
+// trusted tells whether the request comes from a trusted proxy.
+func (id identity) trusted(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range id.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessLog puts a request-scoped logger in the request context and logs
+// every request once it is served.
+func accessLog(id identity) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = logs.NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+			user := id.user(r)
+			entry := logs.FromContext(r.Context()).WithFields(log.Fields{
+				"request_id": requestID,
+				"method":     r.Method,
+				"route":      metrics.Route(r),
+				"user":       user,
+			})
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				entry = entry.WithField("trace_id", sc.TraceID().String())
+			}
+			rec := metrics.NewStatusRecorder(w)
+			ctx := logs.WithRequestID(logs.WithLogger(r.Context(), entry), requestID)
+			ctx = history.WithActor(ctx, user)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			entry.WithFields(log.Fields{
+				"path":    r.URL.Path,
+				"status":  rec.Status,
+				"latency": time.Since(start).Seconds(),
+			}).Info("request")
+		})
+	}
+}
+
+// cors lets the browsers of the allowed origins call the API and answers
+// their preflight requests.
+func cors(c config.CORS) mux.MiddlewareFunc {
+	methods := strings.Join(c.AllowedMethods, ", ")
+	headers := strings.Join(c.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(time.Duration(c.MaxAge).Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" && allowedOrigin(c.AllowedOrigins, origin) {
+				h := w.Header()
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+				h.Set("Access-Control-Expose-Headers", RequestIDHeader)
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					h.Set("Access-Control-Allow-Methods", methods)
+					h.Set("Access-Control-Allow-Headers", headers)
+					h.Set("Access-Control-Max-Age", maxAge)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func allowedOrigin(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// preflight answers the OPTIONS requests of the origins that are not allowed.
+func preflight(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate rejects the requests without an authenticated user, unless
+// auth is disabled. The probes, the metrics and the chat commands, which are
+// signed, stay open.
+func authenticate(id identity) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/version", "/metrics", "/api/chat/command":
+				next.ServeHTTP(w, r)
+				return
+			}
+			if (id.auth.Mode == config.AuthProxy || id.auth.Mode == config.AuthBasic) && id.user(r) == "" {
+				if id.auth.Mode == config.AuthBasic {
+					w.Header().Set("WWW-Authenticate", `Basic realm="api"`)
+				}
+				apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// reqLog returns the logger of the request.
+func reqLog(r *http.Request) *log.Entry {
+	return logs.FromContext(r.Context())
+}
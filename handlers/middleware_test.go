@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/microservices/api/config"
+)
+
+func TestIdentityUser(t *testing.T) {
+	proxy := config.Auth{Mode: config.AuthProxy, TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}}
+	basic := config.Auth{Mode: config.AuthBasic, Users: map[string]string{"jdoe": "secret"}}
+	tests := []struct {
+		name     string
+		auth     config.Auth
+		remote   string
+		header   string
+		user     string
+		password string
+		want     string
+	}{
+		{name: "proxy header", auth: proxy, remote: "10.0.0.1:4000", header: "jdoe", want: "jdoe"},
+		{name: "proxy range", auth: proxy, remote: "192.168.4.2:4000", header: "jdoe", want: "jdoe"},
+		{name: "untrusted proxy", auth: proxy, remote: "10.0.0.2:4000", header: "jdoe"},
+		{name: "proxy without header", auth: proxy, remote: "10.0.0.1:4000", user: "jdoe", password: "secret"},
+		{name: "basic", auth: basic, remote: "10.0.0.1:4000", user: "jdoe", password: "secret", want: "jdoe"},
+		{name: "basic wrong password", auth: basic, remote: "10.0.0.1:4000", user: "jdoe", password: "guess"},
+		{name: "header in basic mode", auth: basic, remote: "10.0.0.1:4000", header: "jdoe"},
+		{name: "no auth", auth: config.Auth{Mode: config.AuthNone}, remote: "10.0.0.1:4000", header: "jdoe", user: "jdoe", password: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tickets", nil)
+			r.RemoteAddr = tt.remote
+			if tt.header != "" {
+				r.Header.Set("X-Remote-User", tt.header)
+			}
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			if got := newIdentity(tt.auth).user(r); got != tt.want {
+				t.Errorf("user = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/schedule"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// scheduleHorizon is how far ahead the calendar feeds reach by default.
+const scheduleHorizon = 30 * 24 * time.Hour
+
+func buildSchedule(s *mgo.Session, cfg Config, r *http.Request) ([]schedule.Shift, error) {
+	session := s.Copy()
+	defer session.Close()
+	users, err := rotation.Engineers(r.Context(), session)
+	if err != nil {
+		return nil, err
+	}
+	horizon := scheduleHorizon
+	if days, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && days > 0 {
+		horizon = time.Duration(days) * 24 * time.Hour
+	}
+	now := time.Now()
+	away, err := rotation.Leaves(r.Context(), session, now, now.Add(horizon))
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Build(users, away, now, now.Add(horizon), cfg.ShiftLength), nil
+}
+
+func writeCalendar(w http.ResponseWriter, r *http.Request, name string, shifts []schedule.Shift) {
+	var buf bytes.Buffer
+	if err := schedule.WriteICS(&buf, name, shifts, time.Now()); err != nil {
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func scheduleCalendar(s *mgo.Session, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shifts, err := buildSchedule(s, cfg, r)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		writeCalendar(w, r, "Dispatch schedule", shifts)
+	}
+}
+
+func userScheduleCalendar(s *mgo.Session, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		uid := vars["uid"]
+		shifts, err := buildSchedule(s, cfg, r)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		writeCalendar(w, r, "Dispatch schedule of "+uid, schedule.ForUser(shifts, uid))
+	}
+}
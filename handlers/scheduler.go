@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/scheduler"
+)
+
+type jobStatus struct {
+	Name string         `json:"name"`
+	Spec string         `json:"spec"`
+	Next time.Time      `json:"next"`
+	Last *scheduler.Run `json:"last"`
+}
+
+func allJobs(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := []jobStatus{}
+		now := time.Now()
+		for _, job := range cfg.Scheduler.Jobs() {
+			status := jobStatus{Name: job.Name, Spec: job.Spec, Next: job.Next(now)}
+			runs, err := cfg.Scheduler.History(job.Name, 1)
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			}
+			if len(runs) > 0 {
+				status.Last = &runs[0]
+			}
+			statuses = append(statuses, status)
+		}
+
+		respBody, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func jobRuns(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		name := vars["name"]
+		limit := 20
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = n
+		}
+		runs, err := cfg.Scheduler.History(name, limit)
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case scheduler.ErrUnknownJob:
+				apierror.Write(w, r, apierror.NotFound("Job not found"))
+				return
+			}
+		}
+
+		respBody, err := json.MarshalIndent(runs, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+// runJob triggers a job by hand and answers with its run once it started;
+// the outcome is polled from the runs of the job.
+func runJob(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		name := vars["name"]
+
+		run, err := cfg.Scheduler.Trigger(name)
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case scheduler.ErrUnknownJob:
+				apierror.Write(w, r, apierror.NotFound("Job not found"))
+				return
+			case scheduler.ErrLeased:
+				apierror.Write(w, r, apierror.Conflict("Job is already running"))
+				return
+			}
+		}
+
+		respBody, err := json.MarshalIndent(run, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		w.Header().Set("Location", r.URL.Path)
+		ResponseWithJSON(w, respBody, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/events"
+	ticket "github.com/microservices/api/tickets"
+)
+
+// heartbeat keeps idle event streams alive through proxies.
+const heartbeat = 30 * time.Second
+
+// eventFilter keeps the events matching the owner and sev query parameters.
+// Rotation events carry no ticket, they are matched against the engineer
+// taking the turn and are dropped when filtering on severity.
+type eventFilter struct {
+	owner string
+	sev   string
+}
+
+func (f eventFilter) match(e events.Event) bool {
+	if e.Ticket == nil {
+		if f.sev != "" {
+			return false
+		}
+		return f.owner == "" || (e.User != nil && e.User.Name == f.owner)
+	}
+	if f.owner != "" && e.Ticket.Owner != f.owner {
+		return false
+	}
+	return f.sev == "" || ticket.SevLevel(e.Ticket.Sev) == ticket.SevLevel(f.sev)
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// streamEvents pushes the events to the client as Server-Sent Events. A
+// client reconnecting with Last-Event-ID first gets the events it missed,
+// or a stream.reset event when they were lost, e.g. across a restart, to
+// reload its state. A client too slow to keep up is disconnected rather than
+// silently skipping events: it reconnects and catches up the same way.
+func streamEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			apierror.Write(w, r, apierror.Internal(errors.New("streaming unsupported")))
+			return
+		}
+		filter := eventFilter{owner: r.URL.Query().Get("owner"), sev: r.URL.Query().Get("sev")}
+		last := r.Header.Get("Last-Event-ID")
+
+		// the stream outlives the write timeout of the server
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			reqLog(r).Error("Can't clear write deadline: ", err)
+		}
+		missed, ch, cancel := events.Default.StreamSince(last, 64)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		for _, e := range missed {
+			if e.Type == events.Reset || filter.match(e) {
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				if !filter.match(e) {
+					continue
+				}
+				if err := writeEvent(w, e); err != nil {
+					reqLog(r).Error("Failed stream event: ", err)
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -1,44 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/importer"
+	"github.com/microservices/api/reports"
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/store"
 	ticket "github.com/microservices/api/tickets"
-	log "github.com/sirupsen/logrus"
+	u "github.com/microservices/api/users"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-var logger *log.Entry
-
-func strToTime(ticket *ticket.Ticket) {
-	var err error
-	if ticket.ISOLastModified, err = time.Parse("2006-01-02 15:04:05", ticket.LastModified); err != nil {
-
-	}
-	if ticket.ISOClosed, err = time.Parse("01/02/2006 15:04", ticket.Closed); err != nil {
-		logger.Error(err)
-	}
-	if ticket.ISOOpened, err = time.Parse("2006-01-02 15:04:05", ticket.Opened); err != nil {
-		logger.Error(err)
-	}
-	if ticket.Parent.ISOCreated, err = time.Parse("2006-01-02 15:04:05", ticket.Parent.Created); err != nil {
-		logger.Error(err)
-	}
-	for i, _ := range ticket.Ith {
-		if ticket.Ith[i].ISODate, err = time.Parse("2006-01-02 15:04:05", ticket.Ith[i].Time); err != nil {
-			logger.Error(err)
-		}
-	}
-	for i, _ := range ticket.Logs {
-		if ticket.Logs[i].ISODate, err = time.Parse("2006-01-02 15:04:05", ticket.Logs[i].Date); err != nil {
-			logger.Error(err)
-		}
+// normalize computes the ISO fields of t, answering with the timestamps that
+// cannot be parsed.
+func normalize(cfg Config, t *ticket.Ticket) error {
+	err := cfg.Normalizer.Normalize(t)
+	if errs, ok := err.(ticket.TimestampErrors); ok {
+		return apierror.Invalid(importer.TimestampFields(errs)...)
 	}
+	return err
 }
 
 func allTickets(s *mgo.Session) http.HandlerFunc {
@@ -46,18 +36,19 @@ func allTickets(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 
 		var tickets []ticket.Ticket
-		err := c.Find(bson.M{}).Sort("isoopened").All(&tickets)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.NotDeleted()).Sort("isoopened").All(&tickets) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -74,18 +65,21 @@ func activeTickets(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 
 		var tickets []ticket.Ticket
 		// db.tickets.find({"state": {$ne: "Closed"}}, {number:1, state:1, owner:1, sev:1})
-		err := c.Find(bson.M{"$and": []bson.M{bson.M{"state": bson.M{"$ne": "Cancel"}}, bson.M{"state": bson.M{"$ne": "Closed"}}}}).Select(sel("number", "owner", "sev", "state", "isoopened", "abstract", "isolastmodified")).Sort("isoopened").All(&tickets)
+		err := mongoOp(r.Context(), c, "find", func() error {
+			return c.Find(store.Open()).Select(sel("number", "owner", "sev", "state", "isoopened", "abstract", "isolastmodified")).Sort("isoopened").All(&tickets)
+		})
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -98,20 +92,18 @@ func reportBacklogTickets(s *mgo.Session) http.HandlerFunc {
 		date := vars["date"]
 		ISODate, err := time.Parse("2006-01-02", date)
 		if err != nil {
-			log.Println("Can't parse date", err)
+			apierror.Write(w, r, apierror.BadRequest("Date must be YYYY-MM-DD"))
+			return
 		}
-		c := session.DB("info").C("tickets")
-
-		var tickets []ticket.Ticket
-		//db.tickets.find({$and:[{isoclosed:{$gt:ISODate("2017-01-31T00:00:00.000Z")}}},{isoopened:{$lte:ISODate("2017-01-31T00:00:00.000Z")}}],{number:1,isoopened:1,isoclosed:1 })
-		err = c.Find(bson.M{"$and": []bson.M{bson.M{"$or": []bson.M{bson.M{"state": bson.M{"$ne": "Closed"}}, bson.M{"isoclosed": bson.M{"$gte": ISODate}}}}, bson.M{"isoopened": bson.M{"$lte": ISODate}}}}).Select(sel("number", "owner", "sev", "state", "isoopened", "abstract", "isoclosed")).Sort("isoopened").All(&tickets)
+		tickets, err := reports.Backlog(r.Context(), session, ISODate)
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -121,19 +113,22 @@ func queuedTickets(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 
 		var tickets []ticket.Ticket
 		// db.tickets.find({"state": {$ne: "Closed"}}, {number:1, state:1, owner:1, sev:1})
-		err := c.Find(bson.M{"state": "Queued"}).Select(sel("number", "owner", "sev", "state", "isolastmodified", "abstract")).All(&tickets)
+		err := mongoOp(r.Context(), c, "find", func() error {
+			return c.Find(store.Live(bson.M{"state": store.Queued()})).Select(sel("number", "owner", "sev", "state", "isolastmodified", "abstract")).All(&tickets)
+		})
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -149,27 +144,29 @@ func reportTickets(s *mgo.Session) http.HandlerFunc {
 		)
 		vars := mux.Vars(r)
 		if week, err = strconv.ParseInt(vars["week"], 10, 32); err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.BadRequest("Week must be a number"))
+			return
 		}
 		if year, err = strconv.ParseInt(vars["year"], 10, 32); err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.BadRequest("Year must be a number"))
+			return
 		}
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 		//project := bson.M{"$project": bson.M{"dayofweek":bson.M{"$dayOfWeek": "$isocreated"}}, "year":bson.M{"$year":"$isocreated"}, "week":bson.M{"$week":"$isocreated"},"number":"$number", "owner":"$owner", "state":"$state", "_id": 0}
 		//match := bson.M{"$match": bson.M{"$and": []interface{}{bson.M{"week":week}, bson.M{"year":year}}}, "_id":0}
 		//operations := []bson.M{project, match}
 		//pipe := c.Pipe(operations)
-		pipe := c.Pipe([]bson.M{{"$project": bson.M{"number": "$number", "owner": "$owner", "isoopened": "$isoopened", "state": "$state", "week": bson.M{"$week": "$isoopened"}, "year": bson.M{"$year": "$isoopened"}}}, {"$match": bson.M{"$and": []interface{}{bson.M{"week": week}, bson.M{"year": year}}}}})
+		pipe := c.Pipe([]bson.M{{"$match": store.NotDeleted()}, {"$project": bson.M{"number": "$number", "owner": "$owner", "isoopened": "$isoopened", "state": "$state", "week": bson.M{"$week": "$isoopened"}, "year": bson.M{"$year": "$isoopened"}}}, {"$match": bson.M{"$and": []interface{}{bson.M{"week": week}, bson.M{"year": year}}}}})
 		var tickets []ticket.Ticket
-		err = pipe.All(&tickets)
+		err = mongoOp(r.Context(), c, "aggregate", func() error { return pipe.All(&tickets) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			log.Println("Failed get report: ", err)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -184,56 +181,68 @@ func reportClosedTickets(s *mgo.Session) http.HandlerFunc {
 		)
 		vars := mux.Vars(r)
 		if week, err = strconv.ParseInt(vars["week"], 10, 32); err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.BadRequest("Week must be a number"))
+			return
 		}
 		if year, err = strconv.ParseInt(vars["year"], 10, 32); err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.BadRequest("Year must be a number"))
+			return
 		}
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 		//project := bson.M{"$project": bson.M{"dayofweek":bson.M{"$dayOfWeek": "$isocreated"}}, "year":bson.M{"$year":"$isocreated"}, "week":bson.M{"$week":"$isocreated"},"number":"$number", "owner":"$owner", "state":"$state", "_id": 0}
 		//match := bson.M{"$match": bson.M{"$and": []interface{}{bson.M{"week":week}, bson.M{"year":year}}}, "_id":0}
 		//operations := []bson.M{project, match}
 		//pipe := c.Pipe(operations)
-		pipe := c.Pipe([]bson.M{{"$project": bson.M{"number": "$number", "owner": "$owner", "isoclosed": "$isoclosed", "week": bson.M{"$week": "$isoclosed"}, "year": bson.M{"$year": "$isoclosed"}}}, {"$match": bson.M{"$and": []interface{}{bson.M{"week": week}, bson.M{"year": year}}}}})
+		pipe := c.Pipe([]bson.M{{"$match": store.NotDeleted()}, {"$project": bson.M{"number": "$number", "owner": "$owner", "isoclosed": "$isoclosed", "week": bson.M{"$week": "$isoclosed"}, "year": bson.M{"$year": "$isoclosed"}}}, {"$match": bson.M{"$and": []interface{}{bson.M{"week": week}, bson.M{"year": year}}}}})
 		var tickets []ticket.Ticket
-		err = pipe.All(&tickets)
+		err = mongoOp(r.Context(), c, "aggregate", func() error { return pipe.All(&tickets) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 		respBody, err := json.MarshalIndent(tickets, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
 }
-func addTicket(s *mgo.Session) http.HandlerFunc {
+func addTicket(s *mgo.Session, cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
 
 		var ticket ticket.Ticket
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&ticket)
-		strToTime(&ticket)
+		err := decodeJSON(r, &ticket)
 		if err != nil {
-			ErrorWithJSON(w, "Incorrect body", http.StatusBadRequest)
+			apierror.Write(w, r, err)
+			return
+		}
+		if err = normalize(cfg, &ticket); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		c := session.DB("info").C("tickets")
+		ticket.ClearServiceFields()
+		c := store.Tickets(session)
 
-		err = c.Insert(ticket)
+		err = mongoOp(r.Context(), c, "insert", func() error { return c.Insert(ticket) })
 		if err != nil {
 			if mgo.IsDup(err) {
-				ErrorWithJSON(w, "Ticket with this number already exists", http.StatusBadRequest)
+				if n, _ := c.Find(bson.M{"$and": []bson.M{bson.M{"number": ticket.Number}, store.Deleted()}}).Count(); n > 0 {
+					apierror.Write(w, r, apierror.Conflict("Ticket is deleted, restore it first"))
+					return
+				}
+				apierror.Write(w, r, apierror.Conflict("Ticket with this number already exists"))
 				return
 			}
 
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
+		history.Record(r.Context(), session, ticket.Number, history.Created, nil, &ticket)
+		events.Publish(events.Event{Type: events.TicketCreated, Ticket: &ticket})
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Location", r.URL.Path+"/"+ticket.Number)
@@ -241,6 +250,50 @@ func addTicket(s *mgo.Session) http.HandlerFunc {
 	}
 }
 
+// maxBulkSize bounds the exports posted to the bulk import.
+const maxBulkSize = 32 << 20
+
+// bulkTickets upserts the tickets of an NDJSON or CSV export and answers
+// with the result of each row. With dryRun=true nothing is written.
+func bulkTickets(s *mgo.Session, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+
+		format := importer.FormatOf(r.Header.Get("Content-Type"))
+		if format == "" {
+			apierror.Write(w, r, apierror.New(apierror.CodeUnsupportedMedia, "Content-Type must be application/x-ndjson or text/csv"))
+			return
+		}
+		rows, err := importer.Read(http.MaxBytesReader(w, r.Body, maxBulkSize), format)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Incorrect body: "+err.Error()))
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+		report, err := importer.Import(r.Context(), session, cfg.Normalizer, rows, dryRun)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		respBody, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func findTicket(ctx context.Context, session *mgo.Session, number string) (ticket.Ticket, error) {
+	c := store.Tickets(session)
+	var t ticket.Ticket
+	err := mongoOp(ctx, c, "find", func() error { return c.Find(store.Live(bson.M{"number": number})).One(&t) })
+	return t, err
+}
+
+// ticketByNumber answers with the ticket, or with the ticket as it was at the
+// RFC 3339 time asOf, rebuilt from its history.
 func ticketByNumber(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
@@ -248,91 +301,268 @@ func ticketByNumber(s *mgo.Session) http.HandlerFunc {
 		vars := mux.Vars(r)
 		number := vars["number"]
 
-		c := session.DB("info").C("tickets")
+		var (
+			ticket ticket.Ticket
+			err    error
+		)
+		if asOf := r.URL.Query().Get("asOf"); asOf != "" {
+			at, perr := time.Parse(time.RFC3339, asOf)
+			if perr != nil {
+				apierror.Write(w, r, apierror.BadRequest("asOf must be an RFC 3339 time"))
+				return
+			}
+			ticket, err = history.AsOf(r.Context(), session, number, at)
+		} else {
+			ticket, err = findTicket(r.Context(), session, number)
+		}
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Ticket not found"))
+				return
+			}
+		}
 
-		var ticket ticket.Ticket
-		err := c.Find(bson.M{"number": number}).One(&ticket)
+		respBody, err := json.MarshalIndent(ticket, "", "  ")
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
-		if ticket.Number == "" {
-			ErrorWithJSON(w, "Ticket not found", http.StatusNotFound)
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+// ticketHistory answers with the writes to the ticket, oldest first.
+func ticketHistory(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		number := vars["number"]
+
+		entries, err := history.Timeline(r.Context(), session, number)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
-
-		respBody, err := json.MarshalIndent(ticket, "", "  ")
+		if len(entries) == 0 {
+			if _, err := findTicket(r.Context(), session, number); err == mgo.ErrNotFound {
+				apierror.Write(w, r, apierror.NotFound("Ticket not found"))
+				return
+			}
+		}
+		respBody, err := json.MarshalIndent(entries, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
-
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
 }
 
-func updateTicket(s *mgo.Session) http.HandlerFunc {
+// updateTicket creates or replaces the ticket of the number. A replacement
+// that changes nothing but the bookkeeping of the source is not written; the
+// answer lists the fields changed. A deleted ticket must be restored first,
+// as for addTicket.
+func updateTicket(s *mgo.Session, cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
 		vars := mux.Vars(r)
 		number := vars["number"]
 
-		var (
-			ticket ticket.Ticket
-			err    error
-		)
-		//requestDump, err := httputil.DumpRequest(r, true)
+		var ticket ticket.Ticket
+		err := decodeJSON(r, &ticket)
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, err)
+			return
 		}
-		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&ticket)
+		if ticket.Number != number {
+			apierror.Write(w, r, apierror.Invalid(apierror.FieldError{Field: "number", Message: "must be the number of the ticket updated"}))
+			return
+		}
+		if err = normalize(cfg, &ticket); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		outcome, err := importer.Upsert(r.Context(), session, &ticket, nil, false)
 		if err != nil {
-			ErrorWithJSON(w, "Incorrect body", http.StatusBadRequest)
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case importer.ErrConflict:
+				apierror.Write(w, r, apierror.Conflict("Ticket changed meanwhile, try again"))
+				return
+			case importer.ErrDeleted:
+				apierror.Write(w, r, apierror.Conflict("Ticket is deleted, restore it first"))
+				return
+			}
+		}
+		if outcome.Result == importer.Inserted {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
 			return
 		}
-		strToTime(&ticket)
-		c := session.DB("info").C("tickets")
-		err = c.Update(bson.M{"number": number}, &ticket)
+		respBody, err := json.MarshalIndent(outcome, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func deleteTicket(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		number := vars["number"]
+
+		c := store.Tickets(session)
+
+		err := mongoOp(r.Context(), c, "update", func() error {
+			return c.Update(store.Live(bson.M{"number": number}), bson.M{"$set": bson.M{"deletedAt": time.Now().UTC(), "deletedBy": history.Actor(r.Context())}, "$inc": bson.M{"version": 1}})
+		})
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-				log.Println("Failed update ticket: ", err)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "Ticket not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("Ticket not found"))
 				return
 			}
 		}
+		history.Record(r.Context(), session, number, history.Deleted, nil, nil)
+		events.Publish(events.Event{Type: events.TicketDeleted, Ticket: &ticket.Ticket{Number: number}})
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func deleteTicket(s *mgo.Session) http.HandlerFunc {
+func restoreTicket(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		number := vars["number"]
+
+		c := store.Tickets(session)
+		var t ticket.Ticket
+		err := mongoOp(r.Context(), c, "findAndModify", func() error {
+			_, err := c.Find(bson.M{"$and": []bson.M{bson.M{"number": number}, store.Deleted()}}).Apply(mgo.Change{
+				Update:    bson.M{"$unset": bson.M{"deletedAt": "", "deletedBy": ""}, "$inc": bson.M{"version": 1}},
+				ReturnNew: true,
+			}, &t)
+			return err
+		})
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Deleted ticket not found"))
+				return
+			}
+		}
+		history.Record(r.Context(), session, number, history.Restored, nil, &t)
+		events.Publish(events.Event{Type: events.TicketRestored, Ticket: &t})
+
+		respBody, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+// ownerChange is the change of the owner of a ticket.
+func ownerChange(from, to string) []ticket.Change {
+	return []ticket.Change{{Field: "owner", From: from, To: to}}
+}
+
+// assignTicket gives the ticket to the next engineer of the pool of its role,
+// falling back to the general pool when nobody qualified is available.
+func assignTicket(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
 		vars := mux.Vars(r)
 		number := vars["number"]
 
-		c := session.DB("info").C("tickets")
+		c := store.Tickets(session)
 
-		err := c.Remove(bson.M{"number": number})
+		var ticket ticket.Ticket
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"number": number})).One(&ticket) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-				log.Println("Failed delete ticket: ", err)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "Ticket not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("Ticket not found"))
 				return
 			}
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		// The turn of the general pool is the dispatcher's: the role-less
+		// tickets go to the next engineer without taking it.
+		now := time.Now()
+		var engineer u.User
+		if ticket.Role == "" {
+			engineer, err = rotation.Next(r.Context(), session, "", now)
+		} else {
+			engineer, err = rotation.Advance(r.Context(), session, ticket.Role, now)
+			if err == rotation.ErrNoEngineer {
+				engineer, err = rotation.Next(r.Context(), session, "", now)
+			}
+		}
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case rotation.ErrNoEngineer:
+				apierror.Write(w, r, apierror.Conflict("No engineer is available"))
+				return
+			}
+		}
+
+		err = mongoOp(r.Context(), c, "update", func() error {
+			return c.Update(store.Live(store.AtVersion(number, ticket.Version)), bson.M{"$set": bson.M{"owner": engineer.Name}, "$inc": bson.M{"version": 1}})
+		})
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.Conflict("Ticket changed meanwhile, try again"))
+				return
+			}
+		}
+		ticket.Version++
+		if ticket.Owner != engineer.Name {
+			previous := ticket.Owner
+			ticket.Owner = engineer.Name
+			history.Record(r.Context(), session, number, history.Updated, ownerChange(previous, engineer.Name), &ticket)
+			events.Publish(events.Event{Type: events.TicketOwnerChanged, Ticket: &ticket, User: &engineer, Previous: previous})
+		}
+
+		respBody, err := json.MarshalIndent(engineer, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	u "github.com/microservices/api/users"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAssignTicket(t *testing.T) {
+	session, h := testRouter(t)
+	users := []u.User{
+		{ID: "jdoe", Name: "jdoe", Active: true, Engineer: true, Current: true},
+		{ID: "asmith", Name: "asmith", Active: true, Engineer: true},
+		{ID: "bking", Name: "bking", Active: true, Engineer: true, Roles: []string{"network"}},
+		{ID: "cdale", Name: "cdale", Active: true, Engineer: true, Roles: []string{"network"}},
+	}
+	for _, user := range users {
+		if err := store.Users(session).Insert(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Rotations(session).Insert(u.Rotation{Role: "network", Current: "bking"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tk := range []ticket.Ticket{{Number: "T1", State: "Open"}, {Number: "T2", State: "Open", Role: "network"}} {
+		if err := store.Tickets(session).Insert(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		number, owner, current, network string
+		version                         int
+	}{
+		{number: "T1", owner: "asmith", current: "jdoe", network: "bking", version: 1},
+		{number: "T1", owner: "asmith", current: "jdoe", network: "bking", version: 2},
+		{number: "T2", owner: "cdale", current: "jdoe", network: "cdale", version: 1},
+	}
+	for _, tt := range tests {
+		w := serve(h, "POST", "/api/ticket/"+tt.number+"/assign", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("assign %s: status %d: %s", tt.number, w.Code, w.Body)
+		}
+		var engineer u.User
+		if err := json.Unmarshal(w.Body.Bytes(), &engineer); err != nil {
+			t.Fatal(err)
+		}
+		var stored ticket.Ticket
+		if err := store.Tickets(session).Find(bson.M{"number": tt.number}).One(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if engineer.Name != tt.owner || stored.Owner != tt.owner || stored.Version != tt.version {
+			t.Errorf("assign %s: engineer %s, owner %s at version %d, want %s at version %d", tt.number, engineer.Name, stored.Owner, stored.Version, tt.owner, tt.version)
+		}
+		for role, want := range map[string]string{"": tt.current, "network": tt.network} {
+			current, err := rotation.Current(context.Background(), session, role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.ID != want {
+				t.Errorf("after assigning %s, the turn of pool %q is %s, want %s", tt.number, role, current.ID, want)
+			}
+		}
+	}
+
+	if w := serve(h, "POST", "/api/ticket/T9/assign", ""); w.Code != http.StatusNotFound {
+		t.Errorf("assign of a missing ticket: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
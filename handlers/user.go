@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"os/user"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/store"
 	u "github.com/microservices/api/users"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -24,35 +30,42 @@ type jobs struct {
 	Tickets []Field `json:"tickets"`
 }
 
+// loadWorkload groups the open tickets by owner.
+func loadWorkload(ctx context.Context, session *mgo.Session) ([]jobs, error) {
+	c := store.Tickets(session)
+	/*
+		db.tickets.aggregate(
+			[{$project: {
+				_id:0,
+				owner:"$owner",
+				num:"$number",
+				status:"$state"}},
+			{$match:{
+				"status": {$ne:"Closed"}}},
+			{$group: {
+				_id:"$owner",
+				num:{$push:{num:"$num", state:"$status"}},
+				total:{ $sum : 1 }}}])
+	*/
+	pipe := c.Pipe([]bson.M{{"$project": bson.M{"number": "$number", "owner": "$owner", "state": "$state", "deletedAt": "$deletedAt"}}, {"$match": store.Open()}, {"$group": bson.M{"_id": "$owner", "tickets": bson.M{"$push": bson.M{"num": "$number", "state": "$state", "owner": "$owner"}}}}})
+	var workloads []jobs
+	err := mongoOp(ctx, c, "aggregate", func() error { return pipe.All(&workloads) })
+	return workloads, err
+}
+
 func workload(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
-		c := session.DB("info").C("tickets")
-		/*
-			db.tickets.aggregate(
-				[{$project: {
-					_id:0,
-					owner:"$owner",
-					num:"$number",
-					status:"$state"}},
-				{$match:{
-					"status": {$ne:"Closed"}}},
-				{$group: {
-					_id:"$owner",
-					num:{$push:{num:"$num", state:"$status"}},
-					total:{ $sum : 1 }}}])
-		*/
-		pipe := c.Pipe([]bson.M{{"$project": bson.M{"number": "$number", "owner": "$owner", "state": "$state"}}, {"$match": bson.M{"$and": []bson.M{bson.M{"state": bson.M{"$ne": "Cancel"}}, bson.M{"state": bson.M{"$ne": "Closed"}}}}}, {"$group": bson.M{"_id": "$owner", "tickets": bson.M{"$push": bson.M{"num": "$number", "state": "$state", "owner": "$owner"}}}}})
-		var workloads []jobs
-		err := pipe.All(&workloads)
-		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			log.Printf("%v", err)
+		workloads, err := loadWorkload(r.Context(), session)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 		respBody, err := json.MarshalIndent(workloads, "", "  ")
 		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -63,19 +76,19 @@ func allUsers(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 
 		var users []user.User
-		err := c.Find(bson.M{"engineer": true}).All(&users)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"engineer": true})).All(&users) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			log.Println("Failed get all users: ", err)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(users, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -85,18 +98,16 @@ func activeUsers(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
-
-		var users []user.User
-		err := c.Find(bson.M{"$and": []bson.M{bson.M{"is_active": true}, bson.M{"engineer": true}}}).All(&users)
+		users, err := rotation.Available(r.Context(), session, time.Now())
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(users, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -106,18 +117,21 @@ func blacklistedUsers(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 
 		var users []user.User
-		err := c.Find(bson.M{"$and": []bson.M{bson.M{"is_active": false}, bson.M{"engineer": true}}}).All(&users)
+		err := mongoOp(r.Context(), c, "find", func() error {
+			return c.Find(bson.M{"$and": []bson.M{bson.M{"active": false}, bson.M{"engineer": true}, store.NotDeleted()}}).All(&users)
+		})
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(users, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -127,18 +141,19 @@ func adminsUsers(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 
 		var users []user.User
-		err := c.Find(bson.M{"is_admin": true}).All(&users)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"admin": true})).All(&users) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		respBody, err := json.MarshalIndent(users, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -148,19 +163,26 @@ func getUser(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 		vars := mux.Vars(r)
 		uid := vars["uid"]
 		var user user.User
-		err := c.Find(bson.M{"id": uid}).One(&user)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			return
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("User not found"))
+				return
+			}
 		}
 
 		respBody, err := json.MarshalIndent(user, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -170,18 +192,24 @@ func currentUser(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		role := r.URL.Query().Get("role")
 
-		var user user.User
-		err := c.Find(bson.M{"current": true}).One(&user)
+		user, err := rotation.Current(r.Context(), session, role)
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			return
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("User not found"))
+				return
+			}
 		}
 
 		respBody, err := json.MarshalIndent(user, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -197,21 +225,32 @@ func updateUser(s *mgo.Session) http.HandlerFunc {
 			user u.User
 			err  error
 		)
-		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&user)
+		err = decodeJSON(r, &user)
 		if err != nil {
-			ErrorWithJSON(w, "Incorrect body", http.StatusBadRequest)
+			apierror.Write(w, r, err)
 			return
 		}
-		c := session.DB("users").C("users")
-		err = c.Update(bson.M{"id": uid}, &user)
+		c := store.Users(session)
+		if user.ID != uid {
+			taken, err := userExists(r.Context(), c, user.ID)
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			}
+			if taken {
+				apierror.Write(w, r, errUserIDTaken)
+				return
+			}
+		}
+		user.DeletedAt, user.DeletedBy = nil, ""
+		err = mongoOp(r.Context(), c, "update", func() error { return c.Update(store.Live(bson.M{"id": uid}), &user) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
@@ -226,92 +265,121 @@ func deleteUser(s *mgo.Session) http.HandlerFunc {
 		vars := mux.Vars(r)
 		uid := vars["uid"]
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 		var user u.User
-		err := c.Find(bson.M{"id": uid}).One(&user)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
-		log.Println(user.Real_Name, " - ", user.Current)
+		reqLog(r).Debug(user.RealName, " - ", user.Current)
 		if user.Current == true {
-			log.Println("This user is current. Please execute next user before delete this")
-			ErrorWithJSON(w, "This user is current. Please execute next user before delete this", http.StatusInternalServerError)
+			reqLog(r).Warn("This user is current. Please execute next user before delete this")
+			apierror.Write(w, r, apierror.Conflict("This user is current. Please execute next user before delete this"))
 			return
 		}
-		err = c.Remove(bson.M{"id": uid})
+		rotations := store.Rotations(session)
+		var pools []u.Rotation
+		err = mongoOp(r.Context(), rotations, "find", func() error { return rotations.Find(bson.M{"current": uid}).All(&pools) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		if len(pools) > 0 {
+			roles := make([]string, len(pools))
+			for i, p := range pools {
+				roles[i] = p.Role
+			}
+			apierror.Write(w, r, apierror.Conflict("This user has the turn of the "+strings.Join(roles, ", ")+" pool. Please execute next user of the pool before delete this"))
+			return
+		}
+		now := time.Now().UTC()
+		user.DeletedAt, user.DeletedBy = &now, history.Actor(r.Context())
+		err = mongoOp(r.Context(), c, "update", func() error {
+			return c.Update(store.Live(bson.M{"id": uid}), bson.M{"$set": bson.M{"deletedAt": now, "deletedBy": user.DeletedBy}})
+		})
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-				log.Println("Failed delete user: ", err)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
 		respBody, err := json.MarshalIndent(user, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
 }
-func nextUser(s *mgo.Session) http.HandlerFunc {
+
+func restoreUser(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
 		defer session.Close()
-		c := session.DB("users").C("users")
-		var users []u.User
-		err := c.Find(bson.M{"$and": []bson.M{bson.M{"is_active": true}, bson.M{"engineer": true}}}).All(&users)
+		vars := mux.Vars(r)
+		uid := vars["uid"]
+
+		c := store.Users(session)
+		var user u.User
+		err := mongoOp(r.Context(), c, "findAndModify", func() error {
+			_, err := c.Find(bson.M{"$and": []bson.M{bson.M{"id": uid}, store.Deleted()}}).Apply(mgo.Change{
+				Update:    bson.M{"$unset": bson.M{"deletedAt": "", "deletedBy": ""}},
+				ReturnNew: true,
+			}, &user)
+			return err
+		})
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			log.Println("Failed get active users for next: ", err)
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Deleted user not found"))
+				return
+			}
+		}
+		respBody, err := json.MarshalIndent(user, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
-		count := len(users)
-		for i, user := range users {
-			if user.Current == true {
-				user.Current = false
-				users[(i+1)%count].Current = true
-				err = c.Update(bson.M{"id": user.ID}, &user)
-				if err != nil {
-					switch err {
-					default:
-						ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-						return
-					case mgo.ErrNotFound:
-						ErrorWithJSON(w, "User not found", http.StatusNotFound)
-						return
-					}
-				}
-				nextUser := users[(i+1)%count]
-				err = c.Update(bson.M{"id": nextUser.ID}, &nextUser)
-				if err != nil {
-					switch err {
-					default:
-						ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-						return
-					case mgo.ErrNotFound:
-						ErrorWithJSON(w, "User not found", http.StatusNotFound)
-						return
-					}
-				}
-				respBody, err := json.MarshalIndent(nextUser, "", "  ")
-				if err != nil {
-					log.Println(err)
-				}
-				ResponseWithJSON(w, respBody, http.StatusOK)
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+func nextUser(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		role := r.URL.Query().Get("role")
+
+		nextUser, err := rotation.Advance(r.Context(), session, role, time.Now())
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case rotation.ErrNoEngineer:
+				apierror.Write(w, r, apierror.Conflict("No engineer is available"))
 				return
 			}
 		}
+		respBody, err := json.MarshalIndent(nextUser, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
 }
 func whitelistUser(s *mgo.Session) http.HandlerFunc {
@@ -325,27 +393,28 @@ func whitelistUser(s *mgo.Session) http.HandlerFunc {
 			user u.User
 			err  error
 		)
-		c := session.DB("users").C("users")
-		err = c.Find(bson.M{"id": uid}).One(&user)
+		c := store.Users(session)
+		err = mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
-		user.Is_Active = true
-		err = c.Update(bson.M{"id": uid}, &user)
+		user.Active = true
+		user.DeletedAt, user.DeletedBy = nil, ""
+		err = mongoOp(r.Context(), c, "update", func() error { return c.Update(store.Live(bson.M{"id": uid}), &user) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
@@ -353,6 +422,7 @@ func whitelistUser(s *mgo.Session) http.HandlerFunc {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
 func blacklistUser(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
@@ -360,36 +430,17 @@ func blacklistUser(s *mgo.Session) http.HandlerFunc {
 		vars := mux.Vars(r)
 		uid := vars["uid"]
 
-		var (
-			user u.User
-			err  error
-		)
-		c := session.DB("users").C("users")
-		err = c.Find(bson.M{"id": uid}).One(&user)
+		_, err := rotation.Blacklist(r.Context(), session, uid)
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
-				return
-			}
-		}
-		log.Println(user.Real_Name, " - ", user.Current)
-		if user.Current == true {
-			ErrorWithJSON(w, "This user is current. Please execute next user before blacklist this", http.StatusInternalServerError)
-			return
-		}
-		user.Is_Active = false
-		err = c.Update(bson.M{"id": uid}, &user)
-		if err != nil {
-			switch err {
-			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
-			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+			case rotation.ErrCurrentUser:
+				apierror.Write(w, r, apierror.Conflict("This user is current. Please execute next user before blacklist this"))
 				return
 			}
 		}
@@ -406,21 +457,35 @@ func isAdmin(s *mgo.Session) http.HandlerFunc {
 			user u.User
 			err  error
 		)
-		c := session.DB("users").C("users")
-		err = c.Find(bson.M{"id": uid}).One(&user)
+		c := store.Users(session)
+		err = mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
 		if err != nil {
 			switch err {
 			default:
-				ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Internal(err))
 				return
 			case mgo.ErrNotFound:
-				ErrorWithJSON(w, "User not found", http.StatusNotFound)
+				apierror.Write(w, r, apierror.NotFound("User not found"))
 				return
 			}
 		}
-		ResponseWithJSON(w, []byte(strconv.FormatBool(user.Is_Admin)), http.StatusOK)
+		ResponseWithJSON(w, []byte(strconv.FormatBool(user.IsAdmin)), http.StatusOK)
 	}
 }
+
+// errUserIDTaken rejects a user whose id is already used by another one.
+var errUserIDTaken = apierror.Conflict("User with this uid already exists").WithFields(apierror.FieldError{Field: "id", Message: "is already taken"})
+
+// userExists tells whether a user has the id uid.
+func userExists(ctx context.Context, c *mgo.Collection, uid string) (bool, error) {
+	var n int
+	err := mongoOp(ctx, c, "count", func() (err error) {
+		n, err = c.Find(bson.M{"id": uid}).Count()
+		return err
+	})
+	return n > 0, err
+}
+
 func addUser(s *mgo.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := s.Copy()
@@ -429,26 +494,35 @@ func addUser(s *mgo.Session) http.HandlerFunc {
 			err error
 		)
 		var user u.User
-		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&user)
+		err = decodeJSON(r, &user)
 		if err != nil {
-			ErrorWithJSON(w, "Incorrect body", http.StatusBadRequest)
+			apierror.Write(w, r, err)
 			return
 		}
-		c := session.DB("users").C("users")
-		err = c.Insert(user)
+		c := store.Users(session)
+		taken, err := userExists(r.Context(), c, user.ID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		if taken {
+			apierror.Write(w, r, errUserIDTaken)
+			return
+		}
+		user.DeletedAt, user.DeletedBy = nil, ""
+		err = mongoOp(r.Context(), c, "insert", func() error { return c.Insert(user) })
 		if err != nil {
 			if mgo.IsDup(err) {
-				ErrorWithJSON(w, "User with this uid already exists", http.StatusBadRequest)
+				apierror.Write(w, r, errUserIDTaken)
 				return
 			}
 
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Location", r.URL.Path+"/"+user.Real_Name)
+		w.Header().Set("Location", r.URL.Path+"/"+user.RealName)
 		w.WriteHeader(http.StatusCreated)
 	}
 }
@@ -457,19 +531,26 @@ func getAttUser(s *mgo.Session) http.HandlerFunc {
 		session := s.Copy()
 		defer session.Close()
 
-		c := session.DB("users").C("users")
+		c := store.Users(session)
 		vars := mux.Vars(r)
 		attuid := vars["attuid"]
 		var user u.User
-		err := c.Find(bson.M{"attuid": attuid}).One(&user)
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(store.Live(bson.M{"attuid": attuid})).One(&user) })
 		if err != nil {
-			ErrorWithJSON(w, "Database error", http.StatusInternalServerError)
-			return
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("User not found"))
+				return
+			}
 		}
 
 		respBody, err := json.MarshalIndent(user, "", "  ")
 		if err != nil {
-			log.Println(err)
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
 		ResponseWithJSON(w, respBody, http.StatusOK)
 	}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/store/storetest"
+	u "github.com/microservices/api/users"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// testRouter serves the API on the Mongo server of MONGO_TEST_URL, see
+// storetest.Session.
+func testRouter(t *testing.T) (*mgo.Session, http.Handler) {
+	session := storetest.Session(t)
+	return session, Router(session, Config{})
+}
+
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeleteRestoreUser(t *testing.T) {
+	session, h := testRouter(t)
+	users := []u.User{
+		{ID: "jdoe", Name: "jdoe", Active: true, Engineer: true},
+		{ID: "asmith", Name: "asmith", Active: true, Engineer: true, Current: true},
+		{ID: "bking", Name: "bking", Active: true, Engineer: true, Roles: []string{"network"}},
+	}
+	for _, user := range users {
+		if err := store.Users(session).Insert(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Rotations(session).Insert(u.Rotation{Role: "network", Current: "bking"}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		method, path string
+		status       int
+	}{
+		{"DELETE", "/api/user/jdoe", http.StatusOK},
+		{"GET", "/api/user/jdoe", http.StatusNotFound},
+		{"DELETE", "/api/user/jdoe", http.StatusNotFound},
+		{"POST", "/api/user/jdoe/restore", http.StatusOK},
+		{"GET", "/api/user/jdoe", http.StatusOK},
+		{"POST", "/api/user/jdoe/restore", http.StatusNotFound},
+		{"DELETE", "/api/user/asmith", http.StatusConflict},
+		{"DELETE", "/api/user/bking", http.StatusConflict},
+		{"DELETE", "/api/user/nobody", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if w := serve(h, step.method, step.path, ""); w.Code != step.status {
+			t.Errorf("%s %s: status %d, want %d: %s", step.method, step.path, w.Code, step.status, w.Body)
+		}
+	}
+}
+
+func TestDeletedTicketMustBeRestored(t *testing.T) {
+	_, h := testRouter(t)
+	body := `{"number":"T1","sev":"2","state":"Queued","opened":"2026-10-19 09:00:00"}`
+	update := `{"number":"T1","sev":"2","state":"Open","opened":"2026-10-19 09:00:00"}`
+
+	steps := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/api/ticket", body, http.StatusCreated},
+		{"DELETE", "/api/tickets/T1", "", http.StatusNoContent},
+		{"GET", "/api/ticket/T1", "", http.StatusNotFound},
+		{"PUT", "/api/ticket/T1", update, http.StatusConflict},
+		{"POST", "/api/ticket", body, http.StatusConflict},
+		{"POST", "/api/tickets/T1/restore", "", http.StatusOK},
+		{"PUT", "/api/ticket/T1", update, http.StatusOK},
+		{"GET", "/api/ticket/T1", "", http.StatusOK},
+	}
+	for _, step := range steps {
+		if w := serve(h, step.method, step.path, step.body); w.Code != step.status {
+			t.Errorf("%s %s: status %d, want %d: %s", step.method, step.path, w.Code, step.status, w.Body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/webhooks"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func allWebhooks(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+
+		c := store.Webhooks(session)
+
+		var subs []webhooks.Subscription
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(bson.M{}).Sort("created").All(&subs) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		// the secret is only shown once, when the webhook is created
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+
+		respBody, err := json.MarshalIndent(subs, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
+
+func addWebhook(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+
+		var sub webhooks.Subscription
+		err := decodeJSON(r, &sub)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			apierror.Write(w, r, apierror.BadRequest("Webhook url must be an absolute http(s) url"))
+			return
+		}
+		for _, t := range sub.Events {
+			if !events.Known(t) {
+				apierror.Write(w, r, apierror.BadRequest("Unknown event type "+strconv.Quote(t)))
+				return
+			}
+		}
+		if sub.Secret == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			}
+			sub.Secret = hex.EncodeToString(secret)
+		}
+		sub.Id = bson.NewObjectId()
+		sub.Active = true
+		sub.Created = time.Now().UTC()
+
+		c := store.Webhooks(session)
+		err = mongoOp(r.Context(), c, "insert", func() error { return c.Insert(sub) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		respBody, err := json.MarshalIndent(sub, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		w.Header().Set("Location", r.URL.Path+"/"+sub.Id.Hex())
+		ResponseWithJSON(w, respBody, http.StatusCreated)
+	}
+}
+
+func deleteWebhook(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		id := vars["id"]
+		if !bson.IsObjectIdHex(id) {
+			apierror.Write(w, r, apierror.NotFound("Webhook not found"))
+			return
+		}
+
+		c := store.Webhooks(session)
+		err := mongoOp(r.Context(), c, "remove", func() error { return c.RemoveId(bson.ObjectIdHex(id)) })
+		if err != nil {
+			switch err {
+			default:
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			case mgo.ErrNotFound:
+				apierror.Write(w, r, apierror.NotFound("Webhook not found"))
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// webhookDeliveries lists the latest delivery attempts of a webhook. The
+// number of entries is set by the limit parameter (100 by default) and
+// ?failed=true keeps only the failed attempts.
+func webhookDeliveries(s *mgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.Copy()
+		defer session.Close()
+		vars := mux.Vars(r)
+		id := vars["id"]
+		if !bson.IsObjectIdHex(id) {
+			apierror.Write(w, r, apierror.NotFound("Webhook not found"))
+			return
+		}
+		limit := 100
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = n
+		}
+		query := bson.M{"subscription": bson.ObjectIdHex(id)}
+		if r.URL.Query().Get("failed") == "true" {
+			query["success"] = false
+		}
+
+		c := store.WebhookDeliveries(session)
+
+		var deliveries []webhooks.Delivery
+		err := mongoOp(r.Context(), c, "find", func() error { return c.Find(query).Sort("-time").Limit(limit).All(&deliveries) })
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		respBody, err := json.MarshalIndent(deliveries, "", "  ")
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		ResponseWithJSON(w, respBody, http.StatusOK)
+	}
+}
@@ -0,0 +1,167 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The actions recorded in the history of a ticket.
+const (
+	Created  = "created"
+	Updated  = "updated"
+	Deleted  = "deleted"
+	Restored = "restored"
+	Purged   = "purged"
+)
+
+// Entry is a write to a ticket: who made it, when and the fields it changed.
+// The entries of a creation, of a restoration and of every SnapshotEvery
+// writes also hold the ticket as it was left, from which the later changes
+// are replayed.
+type Entry struct {
+	ID      bson.ObjectId   `json:"id" bson:"_id"`
+	Number  string          `json:"number" bson:"number"`
+	Time    time.Time       `json:"time" bson:"time"`
+	By      string          `json:"by,omitempty" bson:"by,omitempty"`
+	Action  string          `json:"action" bson:"action"`
+	Changes []ticket.Change `json:"changes,omitempty" bson:"changes,omitempty"`
+	Ticket  *ticket.Ticket  `json:"-" bson:"ticket,omitempty"`
+}
+
+type contextKey int
+
+const actorKey contextKey = iota
+
+// WithActor returns a copy of ctx carrying who makes the writes.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns who makes the writes of ctx, if known.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// SnapshotEvery is the number of writes to a ticket between two snapshots
+// of it in its history.
+const SnapshotEvery = 20
+
+// Record adds a write of the ticket number to its history, made by the
+// actor of ctx. t is the ticket as the write left it, kept as a snapshot
+// when due. The write is already done, so a failure to record it is logged
+// rather than returned.
+func Record(ctx context.Context, session *mgo.Session, number, action string, changes []ticket.Change, t *ticket.Ticket) {
+	c := store.TicketHistory(session)
+	e := Entry{
+		ID:      bson.NewObjectId(),
+		Number:  number,
+		Time:    time.Now().UTC(),
+		By:      Actor(ctx),
+		Action:  action,
+		Changes: changes,
+	}
+	if action != Updated {
+		e.Ticket = t
+	} else if t != nil {
+		var n int
+		err := tracing.MongoOp(ctx, c, "count", func() (err error) {
+			n, err = c.Find(bson.M{"number": number}).Count()
+			return err
+		})
+		if err != nil || n%SnapshotEvery == 0 {
+			e.Ticket = t
+		}
+	}
+	if err := tracing.MongoOp(ctx, c, "insert", func() error { return c.Insert(e) }); err != nil {
+		logs.FromContext(ctx).WithField("number", number).Error("Can't record the ticket history: ", err)
+	}
+}
+
+// Timeline returns the history of the ticket number, oldest first.
+func Timeline(ctx context.Context, session *mgo.Session, number string) ([]Entry, error) {
+	c := store.TicketHistory(session)
+	entries := []Entry{}
+	err := tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{"number": number}).Sort("time", "_id").All(&entries)
+	})
+	return entries, err
+}
+
+// AsOf returns the ticket number as it was at the moment at, replaying the
+// changes recorded since the snapshot before. It returns mgo.ErrNotFound
+// when the ticket did not exist then, or was written before its history was
+// kept.
+func AsOf(ctx context.Context, session *mgo.Session, number string, at time.Time) (ticket.Ticket, error) {
+	c := store.TicketHistory(session)
+	var base Entry
+	err := tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{
+			"number": number,
+			"time":   bson.M{"$lte": at},
+			"$or":    []bson.M{bson.M{"ticket": bson.M{"$exists": true}}, bson.M{"action": bson.M{"$in": []string{Deleted, Purged}}}},
+		}).Sort("-time", "-_id").One(&base)
+	})
+	if err != nil {
+		return ticket.Ticket{}, err
+	}
+	if base.Ticket == nil {
+		return ticket.Ticket{}, mgo.ErrNotFound
+	}
+
+	var entries []Entry
+	err = tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{"number": number, "time": bson.M{"$gte": base.Time, "$lte": at}}).Sort("time", "_id").All(&entries)
+	})
+	if err != nil {
+		return ticket.Ticket{}, err
+	}
+	t := *base.Ticket
+	replay := false
+	for _, e := range entries {
+		if e.ID == base.ID {
+			replay = true
+			continue
+		}
+		if replay {
+			if err := Apply(&t, e.Changes); err != nil {
+				return ticket.Ticket{}, fmt.Errorf("history of %s: %v", number, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// Apply sets the fields of t named by the changes to their new values. The
+// values are those read back from the history, whose types are the generic
+// ones of BSON.
+func Apply(t *ticket.Ticket, changes []ticket.Change) error {
+	for _, c := range changes {
+		field, err := ticket.Field(t, c.Field)
+		if err != nil {
+			return err
+		}
+		data, err := bson.Marshal(bson.M{"v": c.To})
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.Field, err)
+		}
+		var doc struct {
+			V bson.Raw `bson:"v"`
+		}
+		if err := bson.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %v", c.Field, err)
+		}
+		if err := doc.V.Unmarshal(field.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %v", c.Field, err)
+		}
+	}
+	return nil
+}
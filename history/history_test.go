@@ -0,0 +1,87 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// stored returns changes as read back from the history collection.
+func stored(t *testing.T, changes []ticket.Change) []ticket.Change {
+	data, err := bson.Marshal(Entry{ID: bson.NewObjectId(), Changes: changes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Entry
+	if err := bson.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e.Changes
+}
+
+func TestApply(t *testing.T) {
+	at := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	base := ticket.Ticket{
+		Number:    "T1",
+		State:     "Open",
+		Owner:     "jdoe",
+		ISOOpened: at,
+		Parent:    ticket.TicketParent{Number: "P1"},
+		Ith:       []ticket.ITH{{State: "Queued", Time: "2026-10-19 09:00:00", ISODate: at}},
+		Logs:      []ticket.TicketLog{{Date: "2026-10-19 09:00:00", ISODate: at, Info: "opened"}},
+	}
+	tests := []struct {
+		name   string
+		change func(t *ticket.Ticket)
+	}{
+		{name: "fields", change: func(t *ticket.Ticket) { t.State, t.Owner, t.SubrootCause = "Closed", "", "disk" }},
+		{name: "nested", change: func(t *ticket.Ticket) { t.Parent.Sev, t.Parent.ISOCreated = "1", at }},
+		{name: "iso time", change: func(t *ticket.Ticket) { t.ISOClosed = at.Add(time.Hour) }},
+		{
+			name: "list item",
+			change: func(t *ticket.Ticket) {
+				t.Logs = []ticket.TicketLog{{Date: "2026-10-19 09:00:00", ISODate: at, Info: "edited"}}
+			},
+		},
+		{
+			name: "list length",
+			change: func(t *ticket.Ticket) {
+				t.Logs = append(append([]ticket.TicketLog{}, t.Logs...), ticket.TicketLog{Date: "2026-10-19 10:00:00", ISODate: at.Add(time.Hour), User: "jdoe"})
+			},
+		},
+		{name: "emptied list", change: func(t *ticket.Ticket) { t.Ith = nil }},
+		{
+			name: "escalations",
+			change: func(t *ticket.Ticket) {
+				t.Escalations = []ticket.Escalation{{Rule: "sev1", Step: 1, Since: at, Time: at, Notified: []string{"jdoe"}}}
+			},
+		},
+		{name: "deletion", change: func(t *ticket.Ticket) { t.DeletedAt, t.DeletedBy = &at, "admin" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := base
+			tt.change(&want)
+			got := base
+			got.Ith = append([]ticket.ITH(nil), base.Ith...)
+			got.Logs = append([]ticket.TicketLog(nil), base.Logs...)
+			if err := Apply(&got, stored(t, ticket.Diff(base, want))); err != nil {
+				t.Fatal(err)
+			}
+			if changes := ticket.Diff(got, want); len(changes) != 0 {
+				t.Errorf("replayed ticket differs: %+v", changes)
+			}
+		})
+	}
+}
+
+func TestApplyUnknownField(t *testing.T) {
+	tk := ticket.Ticket{Logs: []ticket.TicketLog{{}}}
+	for _, path := range []string{"color", "parent.color", "logs[3].info", "owner.name", "logs[x].info"} {
+		if err := Apply(&tk, []ticket.Change{{Field: path, To: "x"}}); err == nil {
+			t.Errorf("Apply set %s", path)
+		}
+	}
+}
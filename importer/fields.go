@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+
+	ticket "github.com/microservices/api/tickets"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// serviceFields are the fields of a ticket written by the service, never by
+// the source.
+var serviceFields = []string{"escalations", "deletedAt", "deletedBy", "version"}
+
+// derived are the ISO fields computed from the timestamp of a field.
+var derived = map[string]string{
+	"opened":         "isoopened",
+	"lastmodified":   "isolastmodified",
+	"closed":         "isoclosed",
+	"parent.created": "parent.isocreated",
+}
+
+// allFields returns the JSON names of the fields of a ticket written by the
+// source.
+func allFields() []string {
+	var fields []string
+	t := reflect.TypeOf(ticket.Ticket{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); !contains(serviceFields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// merge copies the fields of src named by their JSON paths, e.g. owner or
+// parent.sev, and the ISO fields computed from them, into dst. It returns
+// the $set document of the fields copied, by their stored names. The
+// service fields and the unknown paths are left out.
+func merge(dst, src *ticket.Ticket, fields []string) bson.M {
+	set := bson.M{}
+	copyField := func(path string) {
+		to, name := lookup(reflect.ValueOf(dst).Elem(), path)
+		from, _ := lookup(reflect.ValueOf(src).Elem(), path)
+		if !to.IsValid() {
+			return
+		}
+		to.Set(from)
+		set[name] = from.Interface()
+	}
+	for _, path := range fields {
+		if contains(serviceFields, path) {
+			continue
+		}
+		copyField(path)
+		if iso, ok := derived[path]; ok {
+			copyField(iso)
+		}
+	}
+	return set
+}
+
+// lookup returns the field of the struct v at the JSON path, and its stored
+// path, or an invalid Value when there is no such field.
+func lookup(v reflect.Value, path string) (reflect.Value, string) {
+	var stored []string
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, ""
+		}
+		t := v.Type()
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" && jsonName(f) == name {
+				v, found = v.Field(i), true
+				stored = append(stored, bsonName(f))
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, ""
+		}
+	}
+	return v, strings.Join(stored, ".")
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// bsonName is the name mgo stores the field under.
+func bsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("bson"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
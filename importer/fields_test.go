@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAllFields(t *testing.T) {
+	fields := allFields()
+	for _, name := range serviceFields {
+		if contains(fields, name) {
+			t.Errorf("allFields has the service field %s", name)
+		}
+	}
+	for _, name := range []string{"number", "subroot_cause", "parent", "isoclosed", "ith", "logs"} {
+		if !contains(fields, name) {
+			t.Errorf("allFields misses %s", name)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	at := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	escalations := []ticket.Escalation{{Rule: "sev1", Step: 1}}
+	stored := ticket.Ticket{
+		Number:       "T1",
+		Owner:        "jdoe",
+		State:        "Open",
+		SubrootCause: "disk",
+		Parent:       ticket.TicketParent{Number: "P1", Sev: "2"},
+		Logs:         []ticket.TicketLog{{Date: "2026-10-19 09:00:00", ISODate: at}},
+		Escalations:  escalations,
+		Version:      4,
+	}
+	source := ticket.Ticket{
+		Number:       "T1",
+		State:        "Closed",
+		Closed:       "10/19/2026 09:00",
+		ISOClosed:    at,
+		SubrootCause: "network",
+		Parent:       ticket.TicketParent{Sev: "1"},
+		Version:      9,
+	}
+	tests := []struct {
+		name   string
+		fields []string
+		want   func(t *ticket.Ticket)
+		set    bson.M
+	}{
+		{
+			name:   "columns",
+			fields: []string{"number", "state", "closed", "subroot_cause", "parent.sev"},
+			want: func(t *ticket.Ticket) {
+				t.State, t.Closed, t.ISOClosed, t.SubrootCause, t.Parent.Sev = "Closed", "10/19/2026 09:00", at, "network", "1"
+			},
+			set: bson.M{
+				"number":       "T1",
+				"state":        "Closed",
+				"closed":       "10/19/2026 09:00",
+				"isoclosed":    at,
+				"subrootcause": "network",
+				"parent.sev":   "1",
+			},
+		},
+		{
+			name:   "service and unknown fields are left out",
+			fields: []string{"escalations", "version", "deletedAt", "color", "parent.color", "owner.name"},
+			want:   func(t *ticket.Ticket) {},
+			set:    bson.M{},
+		},
+		{
+			name:   "lists and objects are replaced",
+			fields: []string{"logs", "parent"},
+			want: func(t *ticket.Ticket) {
+				t.Logs, t.Parent = nil, ticket.TicketParent{Sev: "1"}
+			},
+			set: bson.M{"logs": []ticket.TicketLog(nil), "parent": ticket.TicketParent{Sev: "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stored
+			src := source
+			set := merge(&got, &src, tt.fields)
+			want := stored
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("merged ticket %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(set, tt.set) {
+				t.Errorf("$set %v, want %v", set, tt.set)
+			}
+			if !reflect.DeepEqual(got.Escalations, escalations) || got.Version != 4 {
+				t.Error("merge wrote a service field")
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/microservices/api/apierror"
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
+	"github.com/microservices/api/validate"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The results of a row.
+const (
+	Inserted  = "inserted"
+	Updated   = "updated"
+	Unchanged = "unchanged"
+	Rejected  = "rejected"
+)
+
+// Result is what became of a row of an export.
+type Result struct {
+	Line    int                   `json:"line"`
+	Number  string                `json:"number,omitempty"`
+	Result  string                `json:"result"`
+	Changes []ticket.Change       `json:"changes,omitempty"`
+	Errors  []apierror.FieldError `json:"errors,omitempty"`
+}
+
+// Report sums up an import.
+type Report struct {
+	DryRun    bool     `json:"dry_run,omitempty"`
+	Inserted  int      `json:"inserted"`
+	Updated   int      `json:"updated"`
+	Unchanged int      `json:"unchanged"`
+	Rejected  int      `json:"rejected"`
+	Results   []Result `json:"results"`
+}
+
+func (r *Report) add(res Result) {
+	switch res.Result {
+	case Inserted:
+		r.Inserted++
+	case Updated:
+		r.Updated++
+	case Unchanged:
+		r.Unchanged++
+	case Rejected:
+		r.Rejected++
+	}
+	r.Results = append(r.Results, res)
+}
+
+// TimestampFields returns the invalid fields of the timestamps that could
+// not be parsed.
+func TimestampFields(errs ticket.TimestampErrors) []apierror.FieldError {
+	fields := make([]apierror.FieldError, len(errs))
+	for i, e := range errs {
+		fields[i] = apierror.FieldError{Field: e.Field, Message: "must be a time like " + strings.Join(e.Layouts, " or ")}
+	}
+	return fields
+}
+
+// Import upserts the tickets of rows by number, after computing their ISO
+// fields with n, as Upsert does. Only the fields a row supplies are
+// written, e.g. the columns of a CSV export. A ticket equal to the stored
+// one is left unchanged, so that an export can be imported again safely. In
+// a dry run, the report tells what would be done.
+//
+// An error is returned when the database fails; the rows before were
+// imported.
+func Import(ctx context.Context, session *mgo.Session, n ticket.Normalizer, rows []Row, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Results: []Result{}}
+	for _, row := range rows {
+		t := row.Ticket
+		res := Result{Line: row.Line, Number: t.Number, Result: Rejected, Errors: row.Errors}
+		if len(res.Errors) == 0 {
+			if err := n.Normalize(&t); err != nil {
+				errs, ok := err.(ticket.TimestampErrors)
+				if !ok {
+					return report, err
+				}
+				res.Errors = TimestampFields(errs)
+			}
+		}
+		if len(res.Errors) == 0 {
+			outcome, err := Upsert(ctx, session, &t, row.fields, dryRun)
+			fields := invalid(err)
+			switch {
+			case err == ErrConflict:
+				res.Errors = []apierror.FieldError{{Field: "", Message: "kept changing during the import, import it again"}}
+			case err == ErrDeleted:
+				res.Errors = []apierror.FieldError{{Field: "", Message: "is deleted, restore it first"}}
+			case fields != nil:
+				res.Errors = fields
+			case err != nil:
+				return report, err
+			default:
+				res.Result, res.Changes = outcome.Result, outcome.Changes
+			}
+		}
+		report.add(res)
+	}
+	return report, nil
+}
+
+// Outcome is what an upsert did to a ticket.
+type Outcome struct {
+	Result  string          `json:"result"`
+	Changes []ticket.Change `json:"changes"`
+}
+
+// ErrConflict is returned when the ticket kept changing while it was
+// upserted.
+var ErrConflict = errors.New("importer: ticket changed during the upsert")
+
+// ErrDeleted is returned when the ticket is deleted: it must be restored
+// before it is written again.
+var ErrDeleted = errors.New("importer: ticket is deleted")
+
+// invalid returns the fields at fault when err is a validation error.
+func invalid(err error) []apierror.FieldError {
+	if e, ok := err.(*apierror.Error); ok && e.Code == apierror.CodeValidation {
+		return e.Fields
+	}
+	return nil
+}
+
+// retries bounds the attempts of an upsert racing other writes.
+const retries = 3
+
+// Upsert inserts t or updates the stored ticket of its number with the
+// fields the source supplies, named by their JSON paths; nil fields stands
+// for all of them. The escalations and the deletion markers are the
+// service's, and are never written. A write that changes nothing but the
+// bookkeeping of the source is skipped; a real change sets the ISO last
+// modification to now when the source gave none.
+//
+// The update only applies to the version of the ticket it was computed
+// from: when the ticket is written meanwhile, the upsert starts over, and
+// ErrConflict is returned after a few attempts. On success t is the ticket
+// as stored. A deleted ticket is not written: ErrDeleted is returned
+// until it is restored. A new ticket must be valid as a whole, whatever the
+// fields: a validation error from apierror.Invalid is returned otherwise. In
+// a dry run, nothing is written.
+func Upsert(ctx context.Context, session *mgo.Session, t *ticket.Ticket, fields []string, dryRun bool) (Outcome, error) {
+	c := store.Tickets(session)
+	if fields == nil {
+		fields = allFields()
+	}
+	for try := 0; try < retries; try++ {
+		var previous ticket.Ticket
+		err := tracing.MongoOp(ctx, c, "find", func() error { return c.Find(bson.M{"number": t.Number}).One(&previous) })
+		switch {
+		case err == mgo.ErrNotFound:
+			if errs := validate.Struct(t); len(errs) > 0 {
+				return Outcome{}, apierror.Invalid(errs...)
+			}
+			if dryRun {
+				return Outcome{Result: Inserted}, nil
+			}
+			t.ClearServiceFields()
+			err = tracing.MongoOp(ctx, c, "insert", func() error { return c.Insert(t) })
+			if mgo.IsDup(err) {
+				continue
+			}
+			if err != nil {
+				return Outcome{}, err
+			}
+			history.Record(ctx, session, t.Number, history.Created, nil, t)
+			events.Publish(events.Event{Type: events.TicketCreated, Ticket: t})
+			return Outcome{Result: Inserted}, nil
+		case err != nil:
+			return Outcome{}, err
+		case previous.DeletedAt != nil:
+			return Outcome{}, ErrDeleted
+		}
+
+		next := previous
+		set := merge(&next, t, fields)
+		changes := ticket.Diff(previous, next)
+		if !ticket.Significant(changes) {
+			return Outcome{Result: Unchanged, Changes: []ticket.Change{}}, nil
+		}
+		if t.ISOLastModified.IsZero() {
+			next.ISOLastModified = time.Now().UTC()
+			set["isolastmodified"] = next.ISOLastModified
+			changes = ticket.Diff(previous, next)
+		}
+		if dryRun {
+			return Outcome{Result: Updated, Changes: changes}, nil
+		}
+		err = tracing.MongoOp(ctx, c, "update", func() error {
+			return c.Update(store.AtVersion(t.Number, previous.Version), bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+		})
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return Outcome{}, err
+		}
+		next.Version++
+		*t = next
+		history.Record(ctx, session, t.Number, history.Updated, changes, t)
+		events.Publish(events.Event{Type: events.TicketUpdated, Ticket: t, Changes: changes})
+		if t.State != previous.State {
+			events.Publish(events.Event{Type: events.TicketStateChanged, Ticket: t, Previous: previous.State})
+		}
+		if t.Owner != previous.Owner {
+			events.Publish(events.Event{Type: events.TicketOwnerChanged, Ticket: t, Previous: previous.Owner})
+		}
+		return Outcome{Result: Updated, Changes: changes}, nil
+	}
+	return Outcome{}, ErrConflict
+}
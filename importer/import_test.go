@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/store/storetest"
+	ticket "github.com/microservices/api/tickets"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestImportColumns(t *testing.T) {
+	session := storetest.Session(t)
+	stored := ticket.Ticket{Number: "T1", Sev: "2", State: "Open", Opened: "2026-10-19 09:00:00", Owner: "jdoe"}
+	if err := store.Tickets(session).Insert(stored); err != nil {
+		t.Fatal(err)
+	}
+	n, err := ticket.NewNormalizer("UTC", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := Read(strings.NewReader("number,state\nT1,Closed\nT2,Queued\n"), CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Import(context.Background(), session, n, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, res := range report.Results {
+		results = append(results, res.Result)
+	}
+	if want := []string{Updated, Rejected}; !reflect.DeepEqual(results, want) {
+		t.Fatalf("results %q, want %q: %+v", results, want, report.Results)
+	}
+	var fields []string
+	for _, e := range report.Results[1].Errors {
+		fields = append(fields, e.Field)
+	}
+	if want := []string{"sev", "opened"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("the new ticket is rejected for %q, want %q", fields, want)
+	}
+
+	var got ticket.Ticket
+	if err := store.Tickets(session).Find(bson.M{"number": "T1"}).One(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.State != "Closed" || got.Sev != stored.Sev || got.Opened != stored.Opened || got.Owner != stored.Owner {
+		t.Errorf("stored ticket %+v, want the state closed and the other fields kept", got)
+	}
+	if n, err := store.Tickets(session).Find(bson.M{"number": "T2"}).Count(); err != nil || n != 0 {
+		t.Errorf("%d tickets T2 stored, want none", n)
+	}
+}
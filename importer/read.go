@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/microservices/api/apierror"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/validate"
+)
+
+// The formats of the exports of the ticketing system.
+const (
+	NDJSON = "ndjson"
+	CSV    = "csv"
+)
+
+// FormatOf returns the format of an export from its media type or file
+// name, or "" when it is neither NDJSON nor CSV.
+func FormatOf(name string) string {
+	if media, _, err := mime.ParseMediaType(name); err == nil {
+		switch media {
+		case "application/x-ndjson", "application/jsonl", "application/json":
+			return NDJSON
+		case "text/csv":
+			return CSV
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ndjson", ".jsonl", ".json":
+		return NDJSON
+	case ".csv":
+		return CSV
+	}
+	return ""
+}
+
+// Row is a ticket read from a line of an export, with the reasons to
+// reject it if any.
+type Row struct {
+	Line   int
+	Ticket ticket.Ticket
+	Errors []apierror.FieldError
+
+	// fields are the JSON paths of the fields the row supplies.
+	fields []string
+}
+
+// Read reads the tickets of an export in format. A row that cannot be read
+// is returned with its errors; an error is only returned when the export
+// as a whole cannot be read.
+func Read(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case NDJSON:
+		return readNDJSON(r)
+	case CSV:
+		return readCSV(r)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func readNDJSON(r io.Reader) ([]Row, error) {
+	var rows []Row
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		row := Row{Line: line}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Ticket); err != nil {
+			row.Errors = []apierror.FieldError{{Field: "", Message: "is not a ticket: " + err.Error()}}
+		} else {
+			row.fields = keys(data)
+			check(&row)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// columns sets the fields of a ticket from the columns of a CSV export,
+// named as the JSON fields. The ith and logs lists have no column.
+var columns = map[string]func(t *ticket.Ticket, v string){
+	"number":         func(t *ticket.Ticket, v string) { t.Number = v },
+	"sev":            func(t *ticket.Ticket, v string) { t.Sev = v },
+	"state":          func(t *ticket.Ticket, v string) { t.State = v },
+	"abstract":       func(t *ticket.Ticket, v string) { t.Abstract = v },
+	"owner":          func(t *ticket.Ticket, v string) { t.Owner = v },
+	"role":           func(t *ticket.Ticket, v string) { t.Role = v },
+	"dispatch":       func(t *ticket.Ticket, v string) { t.Dispatch = v },
+	"handover":       func(t *ticket.Ticket, v string) { t.Handover = v },
+	"opened":         func(t *ticket.Ticket, v string) { t.Opened = v },
+	"lastmodified":   func(t *ticket.Ticket, v string) { t.LastModified = v },
+	"lastmodifiedby": func(t *ticket.Ticket, v string) { t.LastModifiedBy = v },
+	"closed":         func(t *ticket.Ticket, v string) { t.Closed = v },
+	"restored":       func(t *ticket.Ticket, v string) { t.Restored = v },
+	"rootcause":      func(t *ticket.Ticket, v string) { t.RootCause = v },
+	"subroot_cause":  func(t *ticket.Ticket, v string) { t.SubrootCause = v },
+	"parent.number":  func(t *ticket.Ticket, v string) { t.Parent.Number = v },
+	"parent.sev":     func(t *ticket.Ticket, v string) { t.Parent.Sev = v },
+	"parent.status":  func(t *ticket.Ticket, v string) { t.Parent.Status = v },
+	"parent.created": func(t *ticket.Ticket, v string) { t.Parent.Created = v },
+}
+
+// check validates the fields the row supplies and requires its number. The
+// rules of the other fields only apply when the row is a new ticket, which
+// Upsert checks.
+func check(row *Row) {
+	for _, e := range validate.Struct(&row.Ticket) {
+		if supplies(row.fields, e.Field) {
+			row.Errors = append(row.Errors, e)
+		}
+	}
+	if row.Ticket.Number == "" && !supplies(row.fields, "number") {
+		row.Errors = append(row.Errors, apierror.FieldError{Field: "number", Message: "is required"})
+	}
+}
+
+// supplies reports whether the field of the JSON path is one of fields or
+// is within one of them.
+func supplies(fields []string, path string) bool {
+	for _, f := range fields {
+		if path == f || strings.HasPrefix(path, f+".") || strings.HasPrefix(path, f+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the names of the members of the JSON object data, in order.
+func keys(data []byte) []string {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	setters := make([]func(t *ticket.Ticket, v string), len(header))
+	fields := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if setters[i] = columns[name]; setters[i] == nil {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		fields[i] = name
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			perr, ok := err.(*csv.ParseError)
+			if !ok {
+				return nil, err
+			}
+			rows = append(rows, Row{Line: perr.StartLine, fields: fields, Errors: []apierror.FieldError{{Field: "", Message: perr.Err.Error()}}})
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line, fields: fields}
+		if len(record) != len(header) {
+			row.Errors = []apierror.FieldError{{Field: "", Message: fmt.Sprintf("has %d columns instead of %d", len(record), len(header))}}
+			rows = append(rows, row)
+			continue
+		}
+		for i, v := range record {
+			setters[i](&row.Ticket, strings.TrimSpace(v))
+		}
+		check(&row)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"application/x-ndjson", NDJSON},
+		{"application/json; charset=utf-8", NDJSON},
+		{"text/csv", CSV},
+		{"export.ndjson", NDJSON},
+		{"export.JSONL", NDJSON},
+		{"/tmp/export.csv", CSV},
+		{"export.xlsx", ""},
+		{"text/plain", ""},
+	}
+	for _, tt := range tests {
+		if got := FormatOf(tt.name); got != tt.want {
+			t.Errorf("FormatOf(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// rowSummary is what a test checks of a row.
+type rowSummary struct {
+	Line   int
+	Number string
+	Fields []string
+	Errors []string
+}
+
+func summarize(rows []Row) []rowSummary {
+	summaries := make([]rowSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = rowSummary{Line: row.Line, Number: row.Ticket.Number, Fields: row.fields}
+		for _, e := range row.Errors {
+			summaries[i].Errors = append(summaries[i].Errors, e.Field)
+		}
+	}
+	return summaries
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		export  string
+		want    []rowSummary
+		wantErr bool
+	}{
+		{
+			name:   "ndjson",
+			format: NDJSON,
+			export: `{"number":"T1","sev":"Sev1","state":"Queued","opened":"2026-10-19 09:00:00"}
+
+{"number":"T2","sev":"2","state":"Queued","opened":"2026-10-19 09:00:00","parent":{"sev":"3"},"ith":[{"time":"2026-10-19 09:00:00"}]}
+`,
+			want: []rowSummary{
+				{Line: 1, Number: "T1", Fields: []string{"number", "opened", "sev", "state"}},
+				{Line: 3, Number: "T2", Fields: []string{"ith", "number", "opened", "parent", "sev", "state"}},
+			},
+		},
+		{
+			name:   "ndjson rejected rows",
+			format: NDJSON,
+			export: `{"number":"T1","sev":"9","state":"Queued","opened":"2026-10-19 09:00:00"}
+{"number":"T2","color":"red"}
+not json
+`,
+			want: []rowSummary{
+				{Line: 1, Number: "T1", Fields: []string{"number", "opened", "sev", "state"}, Errors: []string{"sev"}},
+				{Line: 2, Number: "T2", Errors: []string{""}},
+				{Line: 3, Errors: []string{""}},
+			},
+		},
+		{
+			name:   "csv",
+			format: CSV,
+			export: "Number,Sev,State,Opened,Parent.Number\nT1,1,Queued,2026-10-19 09:00:00,P1\nT2,2,Queued\n,2,Queued,2026-10-19 09:00:00,\n",
+			want: []rowSummary{
+				{Line: 2, Number: "T1", Fields: []string{"number", "sev", "state", "opened", "parent.number"}},
+				{Line: 3, Fields: []string{"number", "sev", "state", "opened", "parent.number"}, Errors: []string{""}},
+				{Line: 4, Fields: []string{"number", "sev", "state", "opened", "parent.number"}, Errors: []string{"number"}},
+			},
+		},
+		{
+			name:   "csv supplied columns",
+			format: CSV,
+			export: "number,state\nT1,Closed\nT2,\n,Closed\n",
+			want: []rowSummary{
+				{Line: 2, Number: "T1", Fields: []string{"number", "state"}},
+				{Line: 3, Number: "T2", Fields: []string{"number", "state"}, Errors: []string{"state"}},
+				{Line: 4, Fields: []string{"number", "state"}, Errors: []string{"number"}},
+			},
+		},
+		{
+			name:   "csv without number",
+			format: CSV,
+			export: "state\nClosed\n",
+			want:   []rowSummary{{Line: 2, Fields: []string{"state"}, Errors: []string{"number"}}},
+		},
+		{
+			name:   "ndjson supplied fields",
+			format: NDJSON,
+			export: `{"number":"T1","parent":{"sev":"9"}}
+{"state":"Closed"}
+`,
+			want: []rowSummary{
+				{Line: 1, Number: "T1", Fields: []string{"number", "parent"}, Errors: []string{"parent.sev"}},
+				{Line: 2, Fields: []string{"state"}, Errors: []string{"number"}},
+			},
+		},
+		{
+			name:    "csv unknown column",
+			format:  CSV,
+			export:  "number,color\nT1,red\n",
+			wantErr: true,
+		},
+		{
+			name:   "empty csv",
+			format: CSV,
+			export: "",
+			want:   []rowSummary{},
+		},
+		{
+			name:    "unknown format",
+			format:  "xlsx",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := Read(strings.NewReader(tt.export), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := summarize(rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
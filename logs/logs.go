@@ -0,0 +1,77 @@
+package logs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+var base = log.NewEntry(log.StandardLogger())
+
+// Logger configures the standard logrus logger to write JSON at level (info
+// when empty or invalid) and returns an entry tagged with the service name.
+// That entry is also the fallback of FromContext.
+func Logger(service, level string) *log.Entry {
+	log.SetFormatter(&log.JSONFormatter{})
+	log.SetOutput(os.Stdout)
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		lvl = log.InfoLevel
+	}
+	log.SetLevel(lvl)
+	base = log.WithField("service", service)
+	if err != nil && level != "" {
+		base.Warnf("Unknown log level %q, using info", level)
+	}
+	return base
+}
+
+// Component returns the service logger tagged with the background component
+// writing, e.g. "scheduler".
+func Component(name string) *log.Entry {
+	return base.WithField("component", name)
+}
+
+// WithLogger returns a copy of ctx carrying entry.
+func WithLogger(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey, entry)
+}
+
+// FromContext returns the request-scoped logger of ctx, or the service logger
+// when ctx has none.
+func FromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(loggerKey).(*log.Entry); ok {
+		return entry
+	}
+	return base
+}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id of ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// NewRequestID returns a random identifier for a request.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
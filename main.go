@@ -2,14 +2,35 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/microservices/api/chatops"
+	"github.com/microservices/api/config"
 	"github.com/microservices/api/debug"
+	"github.com/microservices/api/digest"
+	"github.com/microservices/api/escalation"
+	"github.com/microservices/api/events"
 	"github.com/microservices/api/handlers"
 	logs "github.com/microservices/api/logs"
+	"github.com/microservices/api/metrics"
+	"github.com/microservices/api/migrations"
+	"github.com/microservices/api/retention"
+	"github.com/microservices/api/rotation"
+	"github.com/microservices/api/scheduler"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
 	version "github.com/microservices/api/version"
+	"github.com/microservices/api/webhooks"
 	log "github.com/sirupsen/logrus"
 	"goji.io"
 	"golang.org/x/net/context"
@@ -20,66 +41,252 @@ import (
 var logger *log.Entry
 
 func main() {
-	host := os.Getenv("MONGO_HOST")
-	logLevel := os.Getenv("LOG_LEVEL")
-	port := os.Getenv("PORT")
-	profilePort := os.Getenv("PROFILE_PORT")
-	logger = logs.Logger("api", logLevel)
+	conf, args, err := config.Load("api", os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	logger = logs.Logger("api", conf.Log.Level)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if len(args) > 0 {
+		logger.Fatalf("unexpected arguments %q: the maintenance commands are run with apictl", args)
+	}
+	store.Configure(conf)
 	log.WithFields(log.Fields{
 		"service":  "api",
 		"event":    "starting",
 		"commit":   version.Commit,
 		"build":    version.BuildTime,
 		"release":  version.Release,
-		"logLevel": logLevel,
-		"mongodb":  host,
-		"port":     port,
-		"profile":  profilePort}).Info("Starting the API service...")
-
-	// host will have hostname:port
-	logger.Debug(host)
+		"logLevel": conf.Log.Level,
+		"port":     conf.HTTP.Port,
+		"profile":  conf.HTTP.ProfilePort}).Info("Starting the API service...")
 
-	if host == "" {
-		logger.Fatal("MongoDB not set")
-	}
-	if port == "" {
-		logger.Fatal("Port not set")
+	shutdownTracing, err := tracing.Setup("api", conf.Tracing.Exporter)
+	if err != nil {
+		logger.Fatal(err)
 	}
 
-	session, err := mgo.Dial(host)
+	session, err := store.Dial(conf.Mongo)
 	if err != nil {
 		logger.Fatal(err)
 	}
-	defer session.Close()
 	session.SetMode(mgo.Monotonic, true)
-	ensureIndex(session)
+	normalizer, err := ticket.NewNormalizer(conf.Tickets.Timezone, conf.Tickets.Layouts)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if conf.Features.Metrics {
+		// the socket pool gauges of /metrics are read from the mgo stats
+		mgo.SetStats(true)
+		metrics.Register(session, rotation.Available)
+	}
+	var ready int32
+	go func() {
+		for ensureIndex(session) != nil {
+			time.Sleep(10 * time.Second)
+		}
+		if conf.Mongo.MigrateOnStart {
+			for migrate(session, normalizer) != nil {
+				time.Sleep(10 * time.Second)
+			}
+		}
+		atomic.StoreInt32(&ready, 1)
+	}()
 
-	r := handlers.Router(session)
-	if profilePort != "" {
-		prof := debug.Router()
-		go http.ListenAndServe(fmt.Sprintf("0.0.0.0:%s", profilePort), prof)
+	jobs := scheduler.New(session)
+	if conf.Features.Digest && len(conf.Digest.To) > 0 {
+		mailer := digest.Mailer{
+			Addr:     conf.Digest.SMTPAddr,
+			Username: conf.Digest.SMTPUser,
+			Password: conf.Digest.SMTPPassword,
+			From:     conf.Digest.From,
+			To:       conf.Digest.To,
+		}
+		err = jobs.Add("digest", conf.Digest.Schedule, 10*time.Minute, func() error {
+			return digest.Send(session, mailer, time.Now())
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if conf.Features.Escalation {
+		rules := escalation.DefaultRules()
+		if conf.Escalation.Rules != "" {
+			data, err := ioutil.ReadFile(conf.Escalation.Rules)
+			if err != nil {
+				logger.Fatal("Can't read escalation rules: ", err)
+			}
+			if rules, err = escalation.ParseRules(data); err != nil {
+				logger.Fatal("Can't parse escalation rules: ", err)
+			}
+		}
+		err = jobs.Add("escalation", conf.Escalation.Schedule, time.Minute, func() error {
+			return escalation.Evaluate(session, rules, time.Now())
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
 	}
 
-	http.ListenAndServe(fmt.Sprintf("0.0.0.0:%s", port), r)
+	if conf.Features.Retention {
+		period := time.Duration(conf.Retention.Period)
+		err = jobs.Add("retention", conf.Retention.Schedule, 10*time.Minute, func() error {
+			return retention.Purge(session, time.Now().Add(-period))
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	jobs.Start()
+
+	cfg := handlers.Config{
+		ShiftLength:        time.Duration(conf.Rotation.ShiftLength),
+		SlackSigningSecret: conf.Chat.SlackSigningSecret,
+		Scheduler:          jobs,
+		Ready:              func() bool { return atomic.LoadInt32(&ready) == 1 },
+		Auth:               conf.Auth,
+		CORS:               conf.CORS,
+		Features:           conf.Features,
+		Normalizer:         normalizer,
+	}
+	// the event consumers run until the bus is closed and stopHooks with it
+	stopHooks := make(chan struct{})
+	var consumers sync.WaitGroup
+	if conf.Features.Webhooks {
+		dispatcher := webhooks.NewDispatcher(session)
+		events.Default.Hook(dispatcher.Enqueue)
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			dispatcher.Run(stopHooks)
+		}()
+	}
+	if conf.Features.ChatOps && conf.Chat.WebhookURL != "" {
+		notifier, err := chatops.NewNotifier(session, conf.Chat.WebhookURL, loadTemplates(conf.Chat.Templates))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			notifier.Run(events.Default)
+		}()
+	}
+
+	r := handlers.Router(session, cfg)
+	api := newServer(conf.HTTP, conf.HTTP.Port, r)
+	// the event streams only end when their subscription is closed
+	api.RegisterOnShutdown(events.Default.CloseStreams)
+	errc := make(chan error, 2)
+	go serve(api, errc)
+
+	var prof *http.Server
+	if conf.HTTP.ProfilePort != "" {
+		prof = newServer(conf.HTTP, conf.HTTP.ProfilePort, debug.Router())
+		// profiles and traces are written for longer than the write timeout
+		prof.WriteTimeout = 0
+		go serve(prof, errc)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	failed := false
+	select {
+	case sig := <-stop:
+		logger.Info("Shutting down on ", sig)
+	case err := <-errc:
+		logger.Error("Listener failed: ", err)
+		failed = true
+	}
+
+	// drain the in-flight requests before closing the Mongo session
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.HTTP.ShutdownTimeout))
+	defer cancel()
+	if err := api.Shutdown(ctx); err != nil {
+		logger.Error("Failed drain API requests: ", err)
+		failed = true
+	}
+	if prof != nil {
+		if err := prof.Shutdown(ctx); err != nil {
+			logger.Error("Failed drain profiling requests: ", err)
+			failed = true
+		}
+	}
+	// the requests are drained: no more events to hand to the subscribers
+	events.Default.Close()
+	close(stopHooks)
+	consumers.Wait()
+	jobs.Stop()
+	session.Close()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed flush traces: ", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
-func ensureIndex(s *mgo.Session) {
+func newServer(conf config.HTTP, port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%s", port),
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(conf.ReadTimeout),
+		ReadTimeout:       time.Duration(conf.ReadTimeout),
+		WriteTimeout:      time.Duration(conf.WriteTimeout),
+		IdleTimeout:       time.Duration(conf.IdleTimeout),
+	}
+}
+
+// serve runs srv and reports on errc why it stopped, unless it was shut down.
+func serve(srv *http.Server, errc chan<- error) {
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		errc <- fmt.Errorf("%s: %v", srv.Addr, err)
+	}
+}
+
+// loadTemplates reads the chat message templates, a JSON object mapping event
+// types to templates, from path.
+func loadTemplates(path string) map[string]string {
+	templates := make(map[string]string)
+	if path == "" {
+		return templates
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Fatal("Can't read chat templates: ", err)
+	}
+	if err = json.Unmarshal(data, &templates); err != nil {
+		logger.Fatal("Can't parse chat templates: ", err)
+	}
+	return templates
+}
+
+func ensureIndex(s *mgo.Session) error {
 	session := s.Copy()
 	defer session.Close()
 
-	c := session.DB("info").C("tickets")
-
-	index := mgo.Index{
-		Key:        []string{"number"},
-		Unique:     true,
-		DropDups:   true,
-		Background: true,
-		Sparse:     true,
+	err := store.EnsureIndexes(session)
+	if err != nil {
+		logs.Component("store").Error("Can't ensure indexes: ", err)
 	}
-	err := c.EnsureIndex(index)
+	return err
+}
+
+// migrate applies the pending migrations, reporting them in the log.
+func migrate(s *mgo.Session, normalizer ticket.Normalizer) error {
+	session := s.Copy()
+	defer session.Close()
+
+	out := logger.WithField("component", "migrations").Writer()
+	defer out.Close()
+	err := migrations.Up(&migrations.Env{Session: session, Normalizer: normalizer, Out: out}, migrations.All)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Can't migrate: ", err)
 	}
+	return err
 }
 
 func searchZones(s *mgo.Session) goji.HandlerFunc {
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	u "github.com/microservices/api/users"
+	"github.com/prometheus/client_golang/prometheus"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	socketsAlive = prometheus.NewDesc("api_mongo_sockets_alive", "Sockets of the Mongo session pool that are open.", nil, nil)
+	socketsInUse = prometheus.NewDesc("api_mongo_sockets_in_use", "Sockets of the Mongo session pool that are in use.", nil, nil)
+
+	openTickets     = prometheus.NewDesc("api_tickets_open", "Open tickets by state and severity.", []string{"state", "sev"}, nil)
+	queuedTickets   = prometheus.NewDesc("api_tickets_queued", "Tickets in the Queued state.", nil, nil)
+	activeEngineers = prometheus.NewDesc("api_engineers_active", "Engineers taking part in the rotation and not away.", nil, nil)
+	currentUser     = prometheus.NewDesc("api_dispatcher_current", "Set to 1 for the engineer currently on dispatch.", []string{"id", "name"}, nil)
+)
+
+// poolCollector reports the Mongo socket pool from the mgo stats.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- socketsAlive
+	ch <- socketsInUse
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := mgo.GetStats()
+	ch <- prometheus.MustNewConstMetric(socketsAlive, prometheus.GaugeValue, float64(stats.SocketsAlive))
+	ch <- prometheus.MustNewConstMetric(socketsInUse, prometheus.GaugeValue, float64(stats.SocketsInUse))
+}
+
+// Available returns the engineers of the rotation available at t, as
+// rotation.Available does.
+type Available func(ctx context.Context, session *mgo.Session, t time.Time) ([]u.User, error)
+
+// businessCollector queries the ticket and rotation gauges at scrape time.
+type businessCollector struct {
+	session   *mgo.Session
+	available Available
+}
+
+// Register registers the socket pool gauges and the ticket and rotation
+// gauges read from s, the active engineers being those available returns.
+// mgo.SetStats(true) must have been called: mgo has no stats to report
+// otherwise.
+func Register(s *mgo.Session, available Available) {
+	prometheus.MustRegister(poolCollector{}, businessCollector{session: s, available: available})
+}
+
+func (c businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openTickets
+	ch <- queuedTickets
+	ch <- activeEngineers
+	ch <- currentUser
+}
+
+func (c businessCollector) Collect(ch chan<- prometheus.Metric) {
+	session := c.session.Copy()
+	defer session.Close()
+	tickets := store.Tickets(session)
+	users := store.Users(session)
+
+	var groups []struct {
+		Id struct {
+			State string `bson:"state"`
+			Sev   string `bson:"sev"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	err := tickets.Pipe([]bson.M{
+		{"$match": store.Open()},
+		{"$group": bson.M{"_id": bson.M{"state": "$state", "sev": "$sev"}, "count": bson.M{"$sum": 1}}},
+	}).All(&groups)
+	if err != nil {
+		logs.Component("metrics").Error("Can't count open tickets: ", err)
+	}
+	for _, g := range groups {
+		ch <- prometheus.MustNewConstMetric(openTickets, prometheus.GaugeValue, float64(g.Count), g.Id.State, g.Id.Sev)
+	}
+
+	if n, err := tickets.Find(store.Live(bson.M{"state": store.Queued()})).Count(); err != nil {
+		logs.Component("metrics").Error("Can't count queued tickets: ", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(queuedTickets, prometheus.GaugeValue, float64(n))
+	}
+	if engineers, err := c.available(context.Background(), session, time.Now()); err != nil {
+		logs.Component("metrics").Error("Can't count active engineers: ", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(activeEngineers, prometheus.GaugeValue, float64(len(engineers)))
+	}
+	var current struct {
+		ID   string `bson:"id"`
+		Name string `bson:"name"`
+	}
+	if err := users.Find(bson.M{"current": true}).One(&current); err == nil {
+		ch <- prometheus.MustNewConstMetric(currentUser, prometheus.GaugeValue, 1, current.ID, current.Name)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "api",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "api",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	mongoLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "api",
+		Name:      "mongo_operation_duration_seconds",
+		Help:      "Mongo operation latency by collection and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection", "operation"})
+)
+
+func init() {
+	prometheus.MustRegister(requests, latency, mongoLatency)
+}
+
+// StatusRecorder remembers the status code written through it.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *StatusRecorder) WriteHeader(code int) {
+	r.Status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets the event streams flush through the recorder.
+func (r *StatusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the original writer.
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Route returns the path template of the mux route matching r.
+func Route(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
+
+// Middleware counts and times the requests per route template and status.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		labels := prometheus.Labels{"route": Route(r), "method": r.Method, "status": strconv.Itoa(rec.Status)}
+		requests.With(labels).Inc()
+		latency.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveMongo records the latency of an operation on a collection started
+// at start.
+func ObserveMongo(collection, operation string, start time.Time) {
+	mongoLatency.WithLabelValues(collection, operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// All lists the migrations of the service in version order. A migration is
+// never edited or removed once released; new ones are appended.
+var All = []Migration{
+	{Version: 1, Name: "users-id-index", Up: usersIDIndex},
+	{Version: 2, Name: "query-indexes", Up: queryIndexes},
+	{Version: 3, Name: "tickets-iso-timestamps", Up: NormalizeTimestamps},
+	{Version: 4, Name: "webhook-queue-index", Up: webhookQueueIndex},
+	{Version: 5, Name: "legacy-field-names", Up: legacyFieldNames},
+	{Version: 6, Name: "ticket-history-index", Up: ticketHistoryIndex},
+}
+
+// usersIDIndex makes the user ids unique. It fails, leaving the collection
+// as it is, while some ids are taken twice.
+func usersIDIndex(env *Env) error {
+	c := store.Users(env.Session)
+	var dups []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	err := c.Pipe([]bson.M{
+		{"$group": bson.M{"_id": "$id", "count": bson.M{"$sum": 1}}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}).All(&dups)
+	if err != nil {
+		return err
+	}
+	for _, d := range dups {
+		env.Logf("  user id %q is taken by %d users", d.ID, d.Count)
+	}
+	if len(dups) > 0 {
+		return fmt.Errorf("%d user ids are taken more than once", len(dups))
+	}
+	return ensureIndex(env, c, mgo.Index{Key: []string{"id"}, Unique: true, Background: true})
+}
+
+// queryIndexes adds the indexes of the frequent queries on the
+// availability, the job runs and the webhook deliveries.
+func queryIndexes(env *Env) error {
+	indexes := []struct {
+		c     *mgo.Collection
+		index mgo.Index
+	}{
+		{store.Availability(env.Session), mgo.Index{Key: []string{"user_id", "from"}, Background: true}},
+		{store.Availability(env.Session), mgo.Index{Key: []string{"from", "to"}, Background: true}},
+		{store.JobRuns(env.Session), mgo.Index{Key: []string{"job", "-start"}, Background: true}},
+		{store.WebhookDeliveries(env.Session), mgo.Index{Key: []string{"subscription", "-time"}, Background: true}},
+	}
+	for _, i := range indexes {
+		if err := ensureIndex(env, i.c, i.index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookQueueIndex adds the index of the due webhook deliveries.
+func webhookQueueIndex(env *Env) error {
+	return ensureIndex(env, store.WebhookQueue(env.Session), mgo.Index{Key: []string{"due"}, Background: true})
+}
+
+// legacyRenames are the stored fields renamed since the documents were
+// first written with the names of the Go fields.
+var legacyRenames = []struct {
+	collection func(*mgo.Session) *mgo.Collection
+	from, to   string
+}{
+	{store.Users, "is_active", "active"},
+	{store.Users, "real_name", "realname"},
+	{store.Users, "is_admin", "admin"},
+}
+
+// legacyFieldNames renames the legacy fields of the stored documents. When
+// a document already has the new field, the legacy one is dropped.
+func legacyFieldNames(env *Env) error {
+	for _, r := range legacyRenames {
+		c := r.collection(env.Session)
+		legacy := bson.M{r.from: bson.M{"$exists": true}}
+		renamed := bson.M{"$and": []bson.M{legacy, bson.M{r.to: bson.M{"$exists": false}}}}
+		total, err := c.Find(legacy).Count()
+		if err != nil {
+			return err
+		}
+		n, err := c.Find(renamed).Count()
+		if err != nil {
+			return err
+		}
+		env.Logf("  %s: %s renamed to %s in %d documents, dropped from %d", c.FullName, r.from, r.to, n, total-n)
+		if env.DryRun || total == 0 {
+			continue
+		}
+		if _, err := c.UpdateAll(renamed, bson.M{"$rename": bson.M{r.from: r.to}}); err != nil {
+			return fmt.Errorf("%s: %v", c.FullName, err)
+		}
+		if _, err := c.UpdateAll(legacy, bson.M{"$unset": bson.M{r.from: ""}}); err != nil {
+			return fmt.Errorf("%s: %v", c.FullName, err)
+		}
+	}
+	return nil
+}
+
+// ticketHistoryIndex adds the index of the timeline of a ticket.
+func ticketHistoryIndex(env *Env) error {
+	return ensureIndex(env, store.TicketHistory(env.Session), mgo.Index{Key: []string{"number", "time"}, Background: true})
+}
+
+func ensureIndex(env *Env, c *mgo.Collection, index mgo.Index) error {
+	env.Logf("  index %v on %s", index.Key, c.FullName)
+	if env.DryRun {
+		return nil
+	}
+	return c.EnsureIndex(index)
+}
+
+// NormalizeTimestamps recomputes the ISO fields of the stored tickets with
+// the Normalizer of env, recording the changes in their history. The
+// tickets with a timestamp that cannot be parsed are reported and left as
+// they are.
+func NormalizeTimestamps(env *Env) error {
+	ctx := history.WithActor(context.Background(), "migrations")
+	c := store.Tickets(env.Session)
+	iter := c.Find(nil).Iter()
+	var scanned, updated, failed int
+	for {
+		var t ticket.Ticket
+		if !iter.Next(&t) {
+			break
+		}
+		scanned++
+		previous := t
+		previous.Ith = append([]ticket.ITH(nil), t.Ith...)
+		previous.Logs = append([]ticket.TicketLog(nil), t.Logs...)
+		before := isoTimes(t)
+		if err := env.Normalizer.Normalize(&t); err != nil {
+			failed++
+			env.Logf("  ticket %s: can't normalize timestamps: %v", t.Number, err)
+			continue
+		}
+		if sameTimes(before, isoTimes(t)) {
+			continue
+		}
+		updated++
+		if env.DryRun {
+			env.Logf("  ticket %s: timestamps would be updated", t.Number)
+			continue
+		}
+		err := c.Update(bson.M{"number": t.Number}, bson.M{"$set": bson.M{
+			"isoopened":         t.ISOOpened,
+			"isolastmodified":   t.ISOLastModified,
+			"isoclosed":         t.ISOClosed,
+			"parent.isocreated": t.Parent.ISOCreated,
+			"ith":               t.Ith,
+			"logs":              t.Logs,
+		}, "$inc": bson.M{"version": 1}})
+		if err != nil {
+			iter.Close()
+			return fmt.Errorf("ticket %s: %v", t.Number, err)
+		}
+		t.Version++
+		history.Record(ctx, env.Session, t.Number, history.Updated, ticket.Diff(previous, t), &t)
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	env.Logf("  %d tickets scanned, %d updated, %d failed", scanned, updated, failed)
+	return nil
+}
+
+func isoTimes(t ticket.Ticket) []time.Time {
+	times := []time.Time{t.ISOOpened, t.ISOLastModified, t.ISOClosed, t.Parent.ISOCreated}
+	for _, ith := range t.Ith {
+		times = append(times, ith.ISODate)
+	}
+	for _, l := range t.Logs {
+		times = append(times, l.ISODate)
+	}
+	return times
+}
+
+// sameTimes compares the times at the millisecond precision Mongo stores.
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Truncate(time.Millisecond).Equal(b[i].Truncate(time.Millisecond)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Migration is a versioned change of the stored documents. Up must only
+// write when the Env is not a dry run, and should describe what it does or
+// would do with Env.Logf.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(env *Env) error
+}
+
+// Env is what a migration runs with.
+type Env struct {
+	Session *mgo.Session
+	// Normalizer computes the ISO timestamps of the tickets.
+	Normalizer ticket.Normalizer
+	DryRun     bool
+	Out        io.Writer
+}
+
+// Logf writes a line of the report of the migrations.
+func (env *Env) Logf(format string, args ...interface{}) {
+	fmt.Fprintf(env.Out, format+"\n", args...)
+}
+
+// Record is the document stored in the migrations collection for each
+// migration applied, or being applied when Done is false. Owner is the
+// runner that applied it, as host:pid.
+type Record struct {
+	Version  int       `json:"version" bson:"_id"`
+	Name     string    `json:"name" bson:"name"`
+	Owner    string    `json:"owner,omitempty" bson:"owner,omitempty"`
+	Started  time.Time `json:"started" bson:"started"`
+	Finished time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+	Done     bool      `json:"done" bson:"done"`
+}
+
+// Status is the state of a migration in a database.
+type Status struct {
+	Migration
+	Record *Record
+}
+
+// Applied tells whether the migration is done.
+func (s Status) Applied() bool {
+	return s.Record != nil && s.Record.Done
+}
+
+// ErrLocked is returned when a migration is being applied by another runner,
+// or was interrupted; in the latter case its record must be removed from the
+// migrations collection before running it again.
+var ErrLocked = errors.New("migration in progress elsewhere")
+
+// LockedError is the ErrLocked of a migration, with the record of the
+// runner holding it.
+type LockedError struct {
+	Record Record
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%03d %s: %v (%s since %s)", e.Record.Version, e.Record.Name, ErrLocked, e.Record.Owner, e.Record.Started.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// owner names this runner in the records of the migrations it applies.
+var owner = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// Statuses returns the state of each migration of list, in version order.
+func Statuses(session *mgo.Session, list []Migration) ([]Status, error) {
+	var records []Record
+	if err := store.Migrations(session).Find(nil).All(&records); err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Record, len(records))
+	for i := range records {
+		byVersion[records[i].Version] = &records[i]
+	}
+	statuses := make([]Status, len(list))
+	for i, m := range list {
+		statuses[i] = Status{Migration: m, Record: byVersion[m.Version]}
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations of list in version order and stops at
+// the first that fails. Each migration is recorded before it runs, so two
+// runners never apply the same one: a *LockedError is returned for a
+// migration recorded by another runner. In a dry run, nothing is written
+// and the migrations only report what they would do.
+func Up(env *Env, list []Migration) error {
+	statuses, err := Statuses(env.Session, list)
+	if err != nil {
+		return err
+	}
+	c := store.Migrations(env.Session)
+	for _, s := range statuses {
+		m := s.Migration
+		if s.Applied() {
+			continue
+		}
+		if s.Record != nil {
+			return &LockedError{Record: *s.Record}
+		}
+		env.Logf("%03d %s", m.Version, m.Name)
+		if env.DryRun {
+			if err := m.Up(env); err != nil {
+				return fmt.Errorf("%03d %s: %v", m.Version, m.Name, err)
+			}
+			continue
+		}
+
+		record := Record{Version: m.Version, Name: m.Name, Owner: owner, Started: time.Now().UTC()}
+		if err := c.Insert(record); err != nil {
+			if mgo.IsDup(err) {
+				if c.FindId(m.Version).One(&record) != nil {
+					record.Owner = "another runner"
+				}
+				return &LockedError{Record: record}
+			}
+			return err
+		}
+		if err := m.Up(env); err != nil {
+			// release the migration so that it can be retried
+			c.RemoveId(m.Version)
+			return fmt.Errorf("%03d %s: %v", m.Version, m.Name, err)
+		}
+		err := c.UpdateId(m.Version, bson.M{"$set": bson.M{"done": true, "finished": time.Now().UTC()}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that the versions of list are increasing.
+func validate(list []Migration) error {
+	for i := 1; i < len(list); i++ {
+		if list[i].Version <= list[i-1].Version {
+			return fmt.Errorf("migration %d %s is out of order", list[i].Version, list[i].Name)
+		}
+	}
+	return nil
+}
+
+func init() {
+	if err := validate(All); err != nil {
+		panic(err)
+	}
+}
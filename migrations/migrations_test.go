@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	ticket "github.com/microservices/api/tickets"
+)
+
+func TestAllInVersionOrder(t *testing.T) {
+	if err := validate(All); err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, m := range All {
+		if m.Name == "" || m.Up == nil {
+			t.Errorf("migration %d has no name or no Up", m.Version)
+		}
+		if names[m.Name] {
+			t.Errorf("migration name %q is taken twice", m.Name)
+		}
+		names[m.Name] = true
+	}
+}
+
+func TestValidate(t *testing.T) {
+	up := func(*Env) error { return nil }
+	tests := []struct {
+		name    string
+		list    []Migration
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "increasing", list: []Migration{{1, "a", up}, {2, "b", up}, {5, "c", up}}},
+		{name: "duplicate version", list: []Migration{{1, "a", up}, {1, "b", up}}, wantErr: true},
+		{name: "decreasing", list: []Migration{{2, "a", up}, {1, "b", up}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(tt.list); (err != nil) != tt.wantErr {
+				t.Errorf("validate error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSameTimes(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		a, b []time.Time
+		want bool
+	}{
+		{name: "equal", a: []time.Time{at, {}}, b: []time.Time{at, {}}, want: true},
+		{name: "below the millisecond", a: []time.Time{at.Add(300 * time.Microsecond)}, b: []time.Time{at}, want: true},
+		{name: "other timezone", a: []time.Time{at}, b: []time.Time{at.In(time.FixedZone("CDT", -5*3600))}, want: true},
+		{name: "different", a: []time.Time{at}, b: []time.Time{at.Add(time.Millisecond)}, want: false},
+		{name: "different lengths", a: []time.Time{at}, b: []time.Time{at, at}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameTimes(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameTimes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsoTimes(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 10, 19, h, 0, 0, 0, time.UTC) }
+	tk := ticket.Ticket{
+		ISOOpened:       at(1),
+		ISOLastModified: at(2),
+		ISOClosed:       at(3),
+		Parent:          ticket.TicketParent{ISOCreated: at(4)},
+		Ith:             []ticket.ITH{{ISODate: at(5)}},
+		Logs:            []ticket.TicketLog{{ISODate: at(6)}, {ISODate: at(7)}},
+	}
+	want := []time.Time{at(1), at(2), at(3), at(4), at(5), at(6), at(7)}
+	if got := isoTimes(tk); !sameTimes(got, want) {
+		t.Errorf("isoTimes = %v, want %v", got, want)
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var out bytes.Buffer
+	env := &Env{Out: &out}
+	env.Logf("%03d %s", 5, "legacy-field-names")
+	if got := out.String(); got != "005 legacy-field-names\n" {
+		t.Errorf("Logf wrote %q", got)
+	}
+}
+
+func TestLockedError(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	var err error = &LockedError{Record: Record{Version: 6, Name: "legacy-field-names", Owner: "api-1:7", Started: at}}
+	if !errors.Is(err, ErrLocked) {
+		t.Error("LockedError is not ErrLocked")
+	}
+	if want := "006 legacy-field-names: migration in progress elsewhere (api-1:7 since 2026-10-19T09:00:00Z)"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,31 @@
+package reports
+
+import (
+	"context"
+	"time"
+
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	"github.com/microservices/api/tracing"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// backlogFields are the fields of the tickets of a backlog report.
+var backlogFields = []string{"number", "owner", "sev", "state", "isoopened", "abstract", "isoclosed"}
+
+// Backlog returns the tickets that were open at the moment day, oldest
+// first: those opened before and either still open or closed after it.
+func Backlog(ctx context.Context, session *mgo.Session, day time.Time) ([]ticket.Ticket, error) {
+	c := store.Tickets(session)
+	selected := bson.M{}
+	for _, f := range backlogFields {
+		selected[f] = 1
+	}
+	var tickets []ticket.Ticket
+	//db.tickets.find({$and:[{isoclosed:{$gt:ISODate("2017-01-31T00:00:00.000Z")}}},{isoopened:{$lte:ISODate("2017-01-31T00:00:00.000Z")}}],{number:1,isoopened:1,isoclosed:1 })
+	err := tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{"$and": []bson.M{bson.M{"$or": []bson.M{bson.M{"state": bson.M{"$ne": store.Closed()}}, bson.M{"isoclosed": bson.M{"$gte": day}}}}, bson.M{"isoopened": bson.M{"$lte": day}}, store.NotDeleted()}}).Select(selected).Sort("isoopened").All(&tickets)
+	})
+	return tickets, err
+}
@@ -0,0 +1,79 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/microservices/api/history"
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	ticket "github.com/microservices/api/tickets"
+	u "github.com/microservices/api/users"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Purge removes for good the tickets and users deleted before cutoff, with
+// the records of the users. The purge of a ticket is recorded in its
+// history, which is kept.
+func Purge(s *mgo.Session, cutoff time.Time) error {
+	session := s.Copy()
+	defer session.Close()
+	ctx := history.WithActor(context.Background(), "retention")
+	expired := bson.M{"deletedAt": bson.M{"$lt": cutoff}}
+
+	c := store.Tickets(session)
+	var tickets []ticket.Ticket
+	if err := c.Find(expired).Select(bson.M{"number": 1}).All(&tickets); err != nil {
+		return err
+	}
+	purged := 0
+	for _, t := range tickets {
+		err := c.Remove(bson.M{"number": t.Number, "deletedAt": bson.M{"$lt": cutoff}})
+		if err == mgo.ErrNotFound {
+			// restored meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		history.Record(ctx, session, t.Number, history.Purged, nil, nil)
+		purged++
+	}
+
+	users := store.Users(session)
+	var expiredUsers []u.User
+	if err := users.Find(expired).Select(bson.M{"id": 1}).All(&expiredUsers); err != nil {
+		return err
+	}
+	purgedUsers := 0
+	for _, user := range expiredUsers {
+		err := users.Remove(bson.M{"id": user.ID, "deletedAt": bson.M{"$lt": cutoff}})
+		if err == mgo.ErrNotFound {
+			// restored meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := purgeUser(session, user.ID); err != nil {
+			return err
+		}
+		purgedUsers++
+	}
+	if purged > 0 || purgedUsers > 0 {
+		logs.Component("retention").WithField("cutoff", cutoff.Format(time.RFC3339)).
+			Infof("Purged %d tickets and %d users", purged, purgedUsers)
+	}
+	return nil
+}
+
+// purgeUser removes the records of the user uid: its availability, and its
+// turn in the role pools, which goes to the next engineer when advanced.
+func purgeUser(session *mgo.Session, uid string) error {
+	if _, err := store.Availability(session).RemoveAll(bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	_, err := store.Rotations(session).UpdateAll(bson.M{"current": uid}, bson.M{"$unset": bson.M{"current": ""}})
+	return err
+}
@@ -0,0 +1,227 @@
+package rotation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/tracing"
+	u "github.com/microservices/api/users"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrNoEngineer is returned when nobody of a pool can take the turn.
+	ErrNoEngineer = errors.New("no engineer is available")
+	// ErrCurrentUser is returned when an operation would leave the rotation
+	// without a dispatcher.
+	ErrCurrentUser = errors.New("this user is current. Please execute next user before blacklist this")
+	// ErrNotInPool is returned when a user cannot take the turn of a pool.
+	ErrNotInPool = errors.New("the user is not an active engineer of the pool")
+)
+
+// Engineers returns the engineers taking part in the rotation, in rotation
+// order.
+func Engineers(ctx context.Context, session *mgo.Session) ([]u.User, error) {
+	c := store.Users(session)
+	var users []u.User
+	err := tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{"$and": []bson.M{bson.M{"active": true}, bson.M{"engineer": true}, store.NotDeleted()}}).All(&users)
+	})
+	return users, err
+}
+
+// Leaves returns the availability records overlapping [from, to).
+func Leaves(ctx context.Context, session *mgo.Session, from, to time.Time) ([]u.Availability, error) {
+	c := store.Availability(session)
+	var records []u.Availability
+	err := tracing.MongoOp(ctx, c, "find", func() error {
+		return c.Find(bson.M{"$and": []bson.M{bson.M{"from": bson.M{"$lt": to}}, bson.M{"to": bson.M{"$gt": from}}}}).All(&records)
+	})
+	return records, err
+}
+
+// Available returns the engineers of the rotation that are not on leave at
+// the moment t, in rotation order.
+func Available(ctx context.Context, session *mgo.Session, t time.Time) ([]u.User, error) {
+	users, err := Engineers(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	records, err := Leaves(ctx, session, t, t.Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	var available []u.User
+	for _, user := range users {
+		if !u.Away(records, user.ID, t, t.Add(time.Nanosecond)) {
+			available = append(available, user)
+		}
+	}
+	return available, nil
+}
+
+// pool returns the engineers of the pool of role, in rotation order.
+func pool(ctx context.Context, session *mgo.Session, role string) ([]u.User, error) {
+	users, err := Engineers(ctx, session)
+	if err != nil || role == "" {
+		return users, err
+	}
+	var members []u.User
+	for _, user := range users {
+		if user.HasRole(role) {
+			members = append(members, user)
+		}
+	}
+	return members, nil
+}
+
+// Advance hands the turn of the pool of role over to the next engineer that
+// is available at the moment now and returns that engineer. The empty role
+// is the general pool, whose turn is the dispatcher (the current user).
+func Advance(ctx context.Context, session *mgo.Session, role string, now time.Time) (u.User, error) {
+	users, current, next, err := turn(ctx, session, role, now)
+	if err != nil {
+		return u.User{}, err
+	}
+	previous := ""
+	if current >= 0 {
+		previous = users[current].ID
+	}
+	return users[next], hand(ctx, session, role, previous, &users[next])
+}
+
+// Next returns the engineer that Advance would hand the turn of the pool of
+// role over to, leaving the turn where it is.
+func Next(ctx context.Context, session *mgo.Session, role string, now time.Time) (u.User, error) {
+	users, _, next, err := turn(ctx, session, role, now)
+	if err != nil {
+		return u.User{}, err
+	}
+	return users[next], nil
+}
+
+// turn returns the engineers of the pool of role, the index of the one
+// whose turn it is, -1 if none, and the index of the next one available at
+// the moment now.
+func turn(ctx context.Context, session *mgo.Session, role string, now time.Time) ([]u.User, int, int, error) {
+	users, err := pool(ctx, session, role)
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	away, err := Leaves(ctx, session, now, now.Add(time.Nanosecond))
+	if err != nil {
+		return nil, -1, -1, err
+	}
+
+	rotations := store.Rotations(session)
+	var rotation u.Rotation
+	if role != "" {
+		err = tracing.MongoOp(ctx, rotations, "find", func() error { return rotations.FindId(role).One(&rotation) })
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, -1, -1, err
+		}
+	}
+	current := -1
+	for i, user := range users {
+		if (role == "" && user.Current) || (role != "" && user.ID == rotation.Current) {
+			current = i
+			break
+		}
+	}
+
+	count := len(users)
+	for k := 1; k <= count; k++ {
+		i := (current + k + count) % count
+		if !u.Away(away, users[i].ID, now, now.Add(time.Nanosecond)) {
+			return users, current, i, nil
+		}
+	}
+	return nil, -1, -1, ErrNoEngineer
+}
+
+// SetCurrent gives the turn of the pool of role to the user uid, whether
+// available or not, and returns that user.
+func SetCurrent(ctx context.Context, session *mgo.Session, role, uid string) (u.User, error) {
+	users, err := pool(ctx, session, role)
+	if err != nil {
+		return u.User{}, err
+	}
+	previous, err := Current(ctx, session, role)
+	if err != nil && err != mgo.ErrNotFound {
+		return u.User{}, err
+	}
+	for i := range users {
+		if users[i].ID == uid {
+			return users[i], hand(ctx, session, role, previous.ID, &users[i])
+		}
+	}
+	return u.User{}, ErrNotInPool
+}
+
+// hand records that the turn of the pool of role goes from the user previous
+// to next, and announces it.
+func hand(ctx context.Context, session *mgo.Session, role, previous string, next *u.User) error {
+	var err error
+	if role != "" {
+		rotations := store.Rotations(session)
+		err = tracing.MongoOp(ctx, rotations, "upsert", func() error {
+			_, err := rotations.UpsertId(role, bson.M{"$set": bson.M{"current": next.ID}})
+			return err
+		})
+	} else {
+		c := store.Users(session)
+		if previous != "" && previous != next.ID {
+			err = tracing.MongoOp(ctx, c, "update", func() error {
+				return c.Update(bson.M{"id": previous}, bson.M{"$set": bson.M{"current": false}})
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = tracing.MongoOp(ctx, c, "update", func() error { return c.Update(bson.M{"id": next.ID}, bson.M{"$set": bson.M{"current": true}}) })
+		next.Current = true
+	}
+	if err != nil {
+		return err
+	}
+	events.Publish(events.Event{Type: events.RotationAdvanced, User: next, Role: role, Previous: previous})
+	return nil
+}
+
+// Current returns the engineer whose turn it is in the pool of role.
+func Current(ctx context.Context, session *mgo.Session, role string) (u.User, error) {
+	c := store.Users(session)
+	var user u.User
+	if role == "" {
+		err := tracing.MongoOp(ctx, c, "find", func() error { return c.Find(bson.M{"current": true}).One(&user) })
+		return user, err
+	}
+	var rotation u.Rotation
+	rotations := store.Rotations(session)
+	err := tracing.MongoOp(ctx, rotations, "find", func() error { return rotations.FindId(role).One(&rotation) })
+	if err != nil {
+		return user, err
+	}
+	err = tracing.MongoOp(ctx, c, "find", func() error { return c.Find(bson.M{"id": rotation.Current}).One(&user) })
+	return user, err
+}
+
+// Blacklist takes the user uid out of the rotation.
+func Blacklist(ctx context.Context, session *mgo.Session, uid string) (u.User, error) {
+	c := store.Users(session)
+	var user u.User
+	err := tracing.MongoOp(ctx, c, "find", func() error { return c.Find(store.Live(bson.M{"id": uid})).One(&user) })
+	if err != nil {
+		return user, err
+	}
+	if user.Current {
+		return user, ErrCurrentUser
+	}
+	user.Active = false
+	err = tracing.MongoOp(ctx, c, "update", func() error { return c.Update(bson.M{"id": uid}, &user) })
+	return user, err
+}
@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const icalTime = "20060102T150405Z"
+
+// UID identifies the slot of a shift. It depends only on the start of the
+// slot, so calendar clients update the event in place when the rotation
+// changes instead of adding a duplicate.
+func (s Shift) UID() string {
+	return fmt.Sprintf("dispatch-%s@api", s.Start.UTC().Format(icalTime))
+}
+
+// WriteICS renders shifts as an RFC 5545 calendar named name.
+func WriteICS(w io.Writer, name string, shifts []Shift, stamp time.Time) error {
+	b := bufio.NewWriter(w)
+	line := func(format string, a ...interface{}) {
+		writeFolded(b, fmt.Sprintf(format, a...))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//microservices//api//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:%s", escapeText(name))
+	for _, s := range shifts {
+		summary := s.User.RealName
+		if summary == "" {
+			summary = s.User.Name
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s", s.UID())
+		line("DTSTAMP:%s", stamp.UTC().Format(icalTime))
+		line("DTSTART:%s", s.Start.UTC().Format(icalTime))
+		line("DTEND:%s", s.End.UTC().Format(icalTime))
+		line("SUMMARY:%s", escapeText("Dispatch: "+summary))
+		line("DESCRIPTION:%s", escapeText("On-call dispatcher: "+summary+" ("+s.User.Name+")"))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Flush()
+}
+
+// writeFolded writes a content line terminated by CRLF, folding it so that
+// no line is longer than 75 octets (RFC 5545, section 3.1).
+func writeFolded(w *bufio.Writer, l string) {
+	limit := 75
+	for len(l) > limit {
+		cut := limit
+		// do not split a multi-byte UTF-8 sequence
+		for cut > 0 && l[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(l[:cut])
+		w.WriteString("\r\n ")
+		l = l[cut:]
+		limit = 74
+	}
+	w.WriteString(l)
+	w.WriteString("\r\n")
+}
+
+func escapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
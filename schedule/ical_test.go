@@ -0,0 +1,141 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	user "github.com/microservices/api/users"
+)
+
+func TestWriteICS(t *testing.T) {
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	stamp := time.Date(2026, 10, 18, 12, 30, 0, 0, time.FixedZone("CDT", -5*3600))
+	tests := []struct {
+		name   string
+		shifts []Shift
+		want   []string
+	}{
+		{
+			name: "empty",
+			want: []string{
+				"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//microservices//api//EN", "CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH", `X-WR-CALNAME:Dispatch\, network`, "END:VCALENDAR",
+			},
+		},
+		{
+			name: "shift",
+			shifts: []Shift{
+				{User: user.User{Name: "jdoe", RealName: "John Doe"}, Start: start, End: start.Add(24 * time.Hour)},
+				{User: user.User{Name: "asmith"}, Start: start.Add(24 * time.Hour), End: start.Add(48 * time.Hour)},
+			},
+			want: []string{
+				"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//microservices//api//EN", "CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH", `X-WR-CALNAME:Dispatch\, network`,
+				"BEGIN:VEVENT", "UID:dispatch-20261019T000000Z@api", "DTSTAMP:20261018T173000Z",
+				"DTSTART:20261019T000000Z", "DTEND:20261020T000000Z", "SUMMARY:Dispatch: John Doe",
+				"DESCRIPTION:On-call dispatcher: John Doe (jdoe)", "TRANSP:TRANSPARENT", "END:VEVENT",
+				"BEGIN:VEVENT", "UID:dispatch-20261020T000000Z@api", "DTSTAMP:20261018T173000Z",
+				"DTSTART:20261020T000000Z", "DTEND:20261021T000000Z", "SUMMARY:Dispatch: asmith",
+				"DESCRIPTION:On-call dispatcher: asmith (asmith)", "TRANSP:TRANSPARENT", "END:VEVENT",
+				"END:VCALENDAR",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteICS(&out, "Dispatch, network", tt.shifts, stamp); err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(tt.want, "\r\n") + "\r\n"; out.String() != want {
+				t.Errorf("WriteICS =\n%q\nwant\n%q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	accents := strings.Repeat("é", 40)
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"SUMMARY:x", "SUMMARY:x\r\n"},
+		{long[:75], long[:75] + "\r\n"},
+		{long, long[:75] + "\r\n " + long[75:] + "\r\n"},
+		{strings.Repeat("b", 160), strings.Repeat("b", 75) + "\r\n " + strings.Repeat("b", 74) + "\r\n " + strings.Repeat("b", 11) + "\r\n"},
+		{"X:" + accents, "X:" + strings.Repeat("é", 36) + "\r\n " + strings.Repeat("é", 4) + "\r\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := bufio.NewWriter(&out)
+		writeFolded(w, tt.line)
+		w.Flush()
+		if out.String() != tt.want {
+			t.Errorf("writeFolded(%q) = %q, want %q", tt.line, out.String(), tt.want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"John Doe", "John Doe"},
+		{"Doe, John; on call", `Doe\, John\; on call`},
+		{`C:\dispatch`, `C:\\dispatch`},
+		{"line\nbreak\r\nagain", `line\nbreak\nagain`},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.text); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	from := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	users := []user.User{{ID: "jdoe"}, {ID: "asmith", Current: true}, {ID: "bking"}}
+	tests := []struct {
+		name string
+		away []user.Availability
+		want []string
+	}{
+		{name: "rotation from the current user", want: []string{"asmith", "bking", "jdoe", "asmith"}},
+		{
+			name: "away user skipped",
+			away: []user.Availability{{UserID: "bking", From: from.Add(day), To: from.Add(2 * day)}},
+			want: []string{"asmith", "jdoe", "asmith", "bking"},
+		},
+		{
+			name: "everybody away",
+			away: []user.Availability{
+				{UserID: "jdoe", From: from, To: from.Add(5 * day)},
+				{UserID: "asmith", From: from, To: from.Add(5 * day)},
+				{UserID: "bking", From: from, To: from.Add(5 * day)},
+			},
+			want: []string{"asmith", "bking", "jdoe", "asmith"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shifts := Build(users, tt.away, from, from.Add(3*day), day)
+			var got []string
+			for i, s := range shifts {
+				got = append(got, s.User.ID)
+				if want := from.Truncate(day).Add(time.Duration(i) * day); !s.Start.Equal(want) || !s.End.Equal(want.Add(day)) {
+					t.Errorf("shift %d is [%s, %s), want it to start at %s", i, s.Start, s.End, want)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("shifts of %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
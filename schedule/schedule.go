@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"time"
+
+	user "github.com/microservices/api/users"
+)
+
+// Shift is a single dispatch turn of one engineer.
+type Shift struct {
+	User  user.User `json:"user"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Build lays out the rotation into consecutive shifts covering [from, until).
+// users must be in rotation order; the shift containing from belongs to the
+// user marked as current (or to the first user when nobody is current).
+// Engineers on leave according to away are skipped for the shifts they miss.
+// Shift boundaries are aligned to multiples of length so that the same slot
+// always gets the same start time.
+func Build(users []user.User, away []user.Availability, from, until time.Time, length time.Duration) []Shift {
+	var shifts []Shift
+	if len(users) == 0 || length <= 0 {
+		return shifts
+	}
+	next := 0
+	for i, u := range users {
+		if u.Current {
+			next = i
+			break
+		}
+	}
+	for start := from.UTC().Truncate(length); start.Before(until); start = start.Add(length) {
+		end := start.Add(length)
+		// when everybody is away the slot stays with the rotation order
+		for k := 0; k < len(users); k++ {
+			if !user.Away(away, users[(next+k)%len(users)].ID, start, end) {
+				next = (next + k) % len(users)
+				break
+			}
+		}
+		shifts = append(shifts, Shift{User: users[next], Start: start, End: end})
+		next = (next + 1) % len(users)
+	}
+	return shifts
+}
+
+// ForUser returns the shifts of the user with the given id.
+func ForUser(shifts []Shift, id string) []Shift {
+	var out []Shift
+	for _, s := range shifts {
+		if s.User.ID == id {
+			out = append(out, s)
+		}
+	}
+	return out
+}
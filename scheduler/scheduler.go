@@ -0,0 +1,325 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	"github.com/robfig/cron/v3"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Triggers of a run.
+const (
+	Scheduled = "schedule"
+	Manual    = "manual"
+)
+
+var (
+	ErrUnknownJob = errors.New("scheduler: unknown job")
+	// ErrLeased is returned when the job is already running, on this replica
+	// or on another one.
+	ErrLeased = errors.New("scheduler: job is already running")
+	// ErrDone is returned when another replica already ran the job for the
+	// scheduled time.
+	ErrDone = errors.New("scheduler: job already ran for this schedule")
+)
+
+// Job is a unit of periodic work.
+type Job struct {
+	Name string `json:"name"`
+	// Spec is a standard 5-field cron expression ("0 7 * * *") or a
+	// descriptor ("@every 1m", "@daily").
+	Spec string `json:"spec"`
+	// Lease bounds a run: when a replica dies while holding a job, another
+	// one may take the job over after the lease expired.
+	Lease time.Duration `json:"lease"`
+
+	run      func() error
+	schedule cron.Schedule
+	running  sync.Mutex
+}
+
+// Next returns the next scheduled run after t.
+func (j *Job) Next(t time.Time) time.Time {
+	return j.schedule.Next(t)
+}
+
+// Run is the history record of one execution of a job. It is recorded when
+// the job starts, Running until it ends.
+type Run struct {
+	Id       bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	Job      string        `json:"job"`
+	Owner    string        `json:"owner"`
+	Trigger  string        `json:"trigger"`
+	Running  bool          `json:"running"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Success  bool          `json:"success"`
+}
+
+// lease is held by the replica running a job. Slot is the latest scheduled
+// time the job ran for, so that the replicas whose clock fires a moment
+// later skip it rather than run it again.
+type lease struct {
+	Job   string    `bson:"_id"`
+	Owner string    `bson:"owner"`
+	Until time.Time `bson:"until"`
+	Slot  time.Time `bson:"slot,omitempty"`
+}
+
+// Scheduler runs the jobs on their schedules. A Mongo lease makes sure only
+// one replica runs a job at a time.
+type Scheduler struct {
+	session *mgo.Session
+	owner   string
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	names   []string
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New(s *mgo.Session) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		session: s,
+		owner:   fmt.Sprintf("%s:%d", host, os.Getpid()),
+		jobs:    make(map[string]*Job),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(name, spec string, leaseTime time.Duration, run func() error) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("scheduler: job %s: %v", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("scheduler: job %s is already registered", name)
+	}
+	s.jobs[name] = &Job{Name: name, Spec: spec, Lease: leaseTime, run: run, schedule: schedule}
+	s.names = append(s.names, name)
+	return nil
+}
+
+// Jobs returns the registered jobs in registration order.
+func (s *Scheduler) Jobs() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.names))
+	for _, name := range s.names {
+		jobs = append(jobs, s.jobs[name])
+	}
+	return jobs
+}
+
+// Start runs every job on its schedule until Stop is called.
+func (s *Scheduler) Start() {
+	for _, job := range s.Jobs() {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop stops scheduling and waits for the running jobs to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(job *Job) {
+	defer s.wg.Done()
+	for {
+		slot := job.Next(time.Now())
+		timer := time.NewTimer(time.Until(slot))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		run, err := s.execute(job, Scheduled, slot)
+		switch {
+		case err == ErrLeased || err == ErrDone:
+			// another replica has it
+		case err != nil:
+			logs.Component("scheduler").WithField("job", job.Name).Error("Can't run job: ", err)
+		case !run.Success:
+			logs.Component("scheduler").WithField("job", job.Name).Error("Job failed: ", run.Error)
+		}
+	}
+}
+
+// Trigger starts the job now, outside of its schedule, and returns its
+// run as recorded at the start. The job runs in the background, and Stop
+// waits for it; its outcome is in the History of the job.
+func (s *Scheduler) Trigger(name string) (Run, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return Run{}, ErrUnknownJob
+	}
+	run, err := s.begin(job, Manual, time.Time{})
+	if err != nil {
+		return Run{}, err
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.finish(job, time.Time{}, run)
+	}()
+	return run, nil
+}
+
+// execute runs the job under its lease. A scheduled run is for slot, the
+// time it was scheduled at, and is skipped when the slot is already done; a
+// manual run has no slot.
+func (s *Scheduler) execute(job *Job, trigger string, slot time.Time) (Run, error) {
+	run, err := s.begin(job, trigger, slot)
+	if err != nil {
+		return Run{}, err
+	}
+	return s.finish(job, slot, run), nil
+}
+
+// begin takes the lease of the job and records the start of its run.
+func (s *Scheduler) begin(job *Job, trigger string, slot time.Time) (Run, error) {
+	if !job.running.TryLock() {
+		return Run{}, ErrLeased
+	}
+	session := s.session.Copy()
+	defer session.Close()
+	if err := s.acquire(session, job, slot); err != nil {
+		job.running.Unlock()
+		return Run{}, err
+	}
+	run := Run{Id: bson.NewObjectId(), Job: job.Name, Owner: s.owner, Trigger: trigger, Running: true, Start: time.Now().UTC()}
+	if err := store.JobRuns(session).Insert(run); err != nil {
+		logs.Component("scheduler").WithField("job", job.Name).Error("Can't record run: ", err)
+	}
+	return run, nil
+}
+
+// finish runs the job begun with run, renewing its lease meanwhile, then
+// records the outcome and releases the lease.
+func (s *Scheduler) finish(job *Job, slot time.Time, run Run) Run {
+	defer job.running.Unlock()
+	session := s.session.Copy()
+	defer session.Close()
+	defer s.release(session, job, slot)
+
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		s.renew(job, done)
+	}()
+	err := job.run()
+	close(done)
+	<-renewed
+
+	run.Running = false
+	run.End = time.Now().UTC()
+	run.Duration = run.End.Sub(run.Start)
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if _, err := store.JobRuns(session).UpsertId(run.Id, run); err != nil {
+		logs.Component("scheduler").WithField("job", job.Name).Error("Can't record run: ", err)
+	}
+	return run
+}
+
+// renew extends the lease of the job every third of it until done is
+// closed, so that a run longer than the lease is not taken over.
+func (s *Scheduler) renew(job *Job, done <-chan struct{}) {
+	if job.Lease <= 0 {
+		return
+	}
+	ticker := time.NewTicker(job.Lease / 3)
+	defer ticker.Stop()
+	session := s.session.Copy()
+	defer session.Close()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		err := store.JobLeases(session).Update(bson.M{"_id": job.Name, "owner": s.owner}, bson.M{"$set": bson.M{"until": time.Now().UTC().Add(job.Lease)}})
+		if err != nil {
+			logs.Component("scheduler").WithField("job", job.Name).Error("Can't renew lease: ", err)
+		}
+	}
+}
+
+// acquire takes the lease of the job unless another replica holds it or,
+// for a scheduled run, already ran the job for slot.
+func (s *Scheduler) acquire(session *mgo.Session, job *Job, slot time.Time) error {
+	now := time.Now().UTC()
+	c := store.JobLeases(session)
+	change := mgo.Change{
+		Update:    bson.M{"$set": bson.M{"owner": s.owner, "until": now.Add(job.Lease)}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	free := bson.M{"$or": []bson.M{bson.M{"until": bson.M{"$lt": now}}, bson.M{"owner": s.owner}}}
+	query := []bson.M{bson.M{"_id": job.Name}, free}
+	if !slot.IsZero() {
+		query = append(query, bson.M{"$or": []bson.M{bson.M{"slot": bson.M{"$exists": false}}, bson.M{"slot": bson.M{"$lt": slot}}}})
+	}
+	var l lease
+	_, err := c.Find(bson.M{"$and": query}).Apply(change, &l)
+	if mgo.IsDup(err) {
+		if slot.IsZero() {
+			return ErrLeased
+		}
+		var current lease
+		if err := c.FindId(job.Name).One(&current); err == nil && !current.Slot.Before(slot) {
+			return ErrDone
+		}
+		return ErrLeased
+	}
+	return err
+}
+
+// release gives the lease of the job back, recording slot as done.
+func (s *Scheduler) release(session *mgo.Session, job *Job, slot time.Time) {
+	c := store.JobLeases(session)
+	set := bson.M{"until": time.Now().UTC()}
+	if !slot.IsZero() {
+		set["slot"] = slot.UTC()
+	}
+	err := c.Update(bson.M{"_id": job.Name, "owner": s.owner}, bson.M{"$set": set})
+	if err != nil && err != mgo.ErrNotFound {
+		logs.Component("scheduler").WithField("job", job.Name).Error("Can't release lease: ", err)
+	}
+}
+
+// History returns the latest runs of the job, newest first.
+func (s *Scheduler) History(name string, limit int) ([]Run, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	session := s.session.Copy()
+	defer session.Close()
+	var runs []Run
+	err := store.JobRuns(session).Find(bson.M{"job": name}).Sort("-start").Limit(limit).All(&runs)
+	return runs, err
+}
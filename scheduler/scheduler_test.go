@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/microservices/api/store/storetest"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// replicas returns two schedulers on session, as two replicas of the
+// service, each with the job "sync" running run.
+func replicas(t *testing.T, session *mgo.Session, lease time.Duration, run func() error) (*Scheduler, *Scheduler) {
+	a, b := New(session), New(session)
+	a.owner, b.owner = "a:1", "b:1"
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.Add("sync", "@every 1h", lease, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+func TestSlots(t *testing.T) {
+	session := storetest.Session(t)
+	runs := 0
+	a, b := replicas(t, session, time.Minute, func() error { runs++; return nil })
+	slot := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		s    *Scheduler
+		slot time.Time
+		err  error
+	}{
+		{a, slot, nil},
+		{b, slot, ErrDone},
+		{a, slot, ErrDone},
+		{b, slot.Add(-time.Hour), ErrDone},
+		{b, slot.Add(time.Hour), nil},
+		{a, slot.Add(time.Hour), ErrDone},
+	}
+	for i, step := range steps {
+		if _, err := step.s.execute(step.s.jobs["sync"], Scheduled, step.slot); err != step.err {
+			t.Errorf("step %d: %s ran the slot %s: %v, want %v", i, step.s.owner, step.slot, err, step.err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("the job ran %d times, want 2", runs)
+	}
+}
+
+func TestLease(t *testing.T) {
+	session := storetest.Session(t)
+	const lease = 150 * time.Millisecond
+	release := make(chan struct{})
+	a, b := replicas(t, session, lease, func() error { <-release; return nil })
+
+	run, err := a.Trigger("sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Id == "" || !run.Running {
+		t.Errorf("started run %+v, want a running run with an id", run)
+	}
+	for _, s := range []*Scheduler{a, b} {
+		if _, err := s.Trigger("sync"); err != ErrLeased {
+			t.Errorf("%s triggered the running job: %v, want %v", s.owner, err, ErrLeased)
+		}
+	}
+	time.Sleep(3 * lease)
+	if _, err := b.execute(b.jobs["sync"], Scheduled, time.Now()); err != ErrLeased {
+		t.Errorf("the lease expired while the job runs: %v, want %v", err, ErrLeased)
+	}
+
+	close(release)
+	a.Stop()
+	if _, err := b.Trigger("sync"); err != nil {
+		t.Errorf("the released job can't be triggered: %v", err)
+	}
+	b.Stop()
+	if _, err := a.Trigger("missing"); err != ErrUnknownJob {
+		t.Errorf("triggered a missing job: %v, want %v", err, ErrUnknownJob)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	session := storetest.Session(t)
+	results := []error{nil, errors.New("disk full"), nil}
+	i := 0
+	a, _ := replicas(t, session, time.Minute, func() error { i++; return results[i-1] })
+	slot := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	for k := range results {
+		if _, err := a.execute(a.jobs["sync"], Scheduled, slot.Add(time.Duration(k)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := a.History("sync", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("%d runs, want 2", len(runs))
+	}
+	if !runs[0].Success || runs[1].Success || runs[1].Error != "disk full" {
+		t.Errorf("runs %+v, want the latest success then the failure", runs)
+	}
+	for _, run := range runs {
+		if run.Running || run.Owner != "a:1" || run.Trigger != Scheduled || run.End.Before(run.Start) {
+			t.Errorf("run %+v", run)
+		}
+	}
+	if _, err := a.History("missing", 1); err != ErrUnknownJob {
+		t.Errorf("History of a missing job: %v, want %v", err, ErrUnknownJob)
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/microservices/api/config"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	collections = config.Default().Mongo.Collections
+	states      = config.Default().Tickets
+)
+
+// Configure sets the collections and ticket states used by the service.
+// It must be called before serving.
+func Configure(cfg config.Config) {
+	collections = cfg.Mongo.Collections
+	states = cfg.Tickets
+}
+
+// Dial connects to the Mongo servers of cfg.
+func Dial(cfg config.Mongo) (*mgo.Session, error) {
+	info, err := mgo.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	info.Timeout = time.Duration(cfg.DialTimeout)
+	if cfg.Username != "" {
+		info.Username = cfg.Username
+		info.Password = cfg.Password
+	}
+	if cfg.AuthSource != "" {
+		info.Source = cfg.AuthSource
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLS.InsecureSkipVerify}
+		if cfg.TLS.CAFile != "" {
+			pem, err := ioutil.ReadFile(cfg.TLS.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			if tlsConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+				tlsConfig.RootCAs = x509.NewCertPool()
+			}
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s: no certificate found", cfg.TLS.CAFile)
+			}
+		}
+		dialer := &net.Dialer{Timeout: info.Timeout}
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), tlsConfig)
+		}
+	}
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return nil, err
+	}
+	session.SetSocketTimeout(time.Duration(cfg.OpTimeout))
+	return session, nil
+}
+
+func collection(s *mgo.Session, full string) *mgo.Collection {
+	db, name := config.SplitCollection(full)
+	return s.DB(db).C(name)
+}
+
+func Tickets(s *mgo.Session) *mgo.Collection { return collection(s, collections.Tickets) }
+
+func Defects(s *mgo.Session) *mgo.Collection { return collection(s, collections.Defects) }
+
+func Users(s *mgo.Session) *mgo.Collection { return collection(s, collections.Users) }
+
+func Availability(s *mgo.Session) *mgo.Collection { return collection(s, collections.Availability) }
+
+func Rotations(s *mgo.Session) *mgo.Collection { return collection(s, collections.Rotations) }
+
+func Webhooks(s *mgo.Session) *mgo.Collection { return collection(s, collections.Webhooks) }
+
+func WebhookDeliveries(s *mgo.Session) *mgo.Collection {
+	return collection(s, collections.WebhookDeliveries)
+}
+
+func WebhookQueue(s *mgo.Session) *mgo.Collection { return collection(s, collections.WebhookQueue) }
+
+func JobLeases(s *mgo.Session) *mgo.Collection { return collection(s, collections.JobLeases) }
+
+func JobRuns(s *mgo.Session) *mgo.Collection { return collection(s, collections.JobRuns) }
+
+func Migrations(s *mgo.Session) *mgo.Collection { return collection(s, collections.Migrations) }
+
+func TicketHistory(s *mgo.Session) *mgo.Collection { return collection(s, collections.TicketHistory) }
+
+// Queued is the state of the tickets waiting for an owner.
+func Queued() string { return states.Queued }
+
+// Closed is the state of the resolved tickets.
+func Closed() string { return states.Closed }
+
+// Open matches the tickets that are neither closed nor cancelled, nor
+// deleted.
+func Open() bson.M {
+	return bson.M{"$and": []bson.M{bson.M{"state": bson.M{"$ne": states.Cancelled}}, bson.M{"state": bson.M{"$ne": states.Closed}}, NotDeleted()}}
+}
+
+// NotDeleted matches the tickets and users that are not deleted.
+func NotDeleted() bson.M {
+	return bson.M{"deletedAt": bson.M{"$exists": false}}
+}
+
+// Deleted matches the tickets and users that are deleted.
+func Deleted() bson.M {
+	return bson.M{"deletedAt": bson.M{"$exists": true}}
+}
+
+// Live restricts query to the documents that are not deleted.
+func Live(query bson.M) bson.M {
+	if len(query) == 0 {
+		return NotDeleted()
+	}
+	return bson.M{"$and": []bson.M{query, NotDeleted()}}
+}
+
+// AtVersion selects the ticket of the number at version v. The tickets
+// stored before they were versioned are at version 0.
+func AtVersion(number string, v int) bson.M {
+	if v == 0 {
+		return bson.M{"number": number, "version": bson.M{"$in": []interface{}{0, nil}}}
+	}
+	return bson.M{"number": number, "version": v}
+}
+
+// EnsureIndexes creates the indexes the service relies on: the ticket
+// numbers are unique. The other indexes are added by the migrations.
+func EnsureIndexes(s *mgo.Session) error {
+	return Tickets(s).EnsureIndex(mgo.Index{
+		Key:        []string{"number"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+		Sparse:     true,
+	})
+}
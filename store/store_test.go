@@ -0,0 +1,23 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAtVersion(t *testing.T) {
+	tests := []struct {
+		version int
+		want    bson.M
+	}{
+		{0, bson.M{"number": "T1", "version": bson.M{"$in": []interface{}{0, nil}}}},
+		{3, bson.M{"number": "T1", "version": 3}},
+	}
+	for _, tt := range tests {
+		if got := AtVersion("T1", tt.version); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AtVersion(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
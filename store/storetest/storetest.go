@@ -0,0 +1,53 @@
+// Package storetest runs tests against the Mongo server of MONGO_TEST_URL.
+package storetest
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/microservices/api/config"
+	"github.com/microservices/api/store"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Session dials the Mongo server of MONGO_TEST_URL and points the store at
+// the collections of a database of the test, dropped afterwards. The test is
+// skipped without a server.
+func Session(t *testing.T) *mgo.Session {
+	url := os.Getenv("MONGO_TEST_URL")
+	if url == "" {
+		t.Skip("MONGO_TEST_URL is not set")
+	}
+	session, err := mgo.DialWithTimeout(url, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := fmt.Sprintf("api_test_%d", time.Now().UnixNano())
+	conf := config.Default()
+	conf.Mongo.Collections = config.Collections{
+		Tickets:           db + ".tickets",
+		Defects:           db + ".defect",
+		Users:             db + ".users",
+		Availability:      db + ".availability",
+		Rotations:         db + ".rotations",
+		Webhooks:          db + ".webhooks",
+		WebhookDeliveries: db + ".webhook_deliveries",
+		WebhookQueue:      db + ".webhook_queue",
+		JobLeases:         db + ".job_leases",
+		JobRuns:           db + ".job_runs",
+		Migrations:        db + ".migrations",
+		TicketHistory:     db + ".ticket_history",
+	}
+	store.Configure(conf)
+	if err := store.EnsureIndexes(session); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		session.DB(db).DropDatabase()
+		session.Close()
+		store.Configure(config.Default())
+	})
+	return session
+}
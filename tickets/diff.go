@@ -0,0 +1,139 @@
+package tickets
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change is a field of a ticket whose value changed, named by its JSON path.
+type Change struct {
+	Field string      `json:"field" bson:"field"`
+	From  interface{} `json:"from" bson:"from"`
+	To    interface{} `json:"to" bson:"to"`
+}
+
+// bookkeeping are the fields that the ticketing system updates on every
+// write: they are part of a diff but make no change by themselves.
+var bookkeeping = []string{"lastmodified", "isolastmodified", "lastmodifiedby"}
+
+// Diff returns the fields that differ from a to b, so that b is a with each
+// field set to its To value. A list whose length changed is reported as a
+// whole.
+func Diff(a, b Ticket) []Change {
+	var changes []Change
+	diff("", reflect.ValueOf(a), reflect.ValueOf(b), &changes)
+	return changes
+}
+
+// Significant tells whether changes hold more than bookkeeping.
+func Significant(changes []Change) bool {
+	for _, c := range changes {
+		if !contains(bookkeeping, c.Field) {
+			return true
+		}
+	}
+	return false
+}
+
+func diff(path string, a, b reflect.Value, changes *[]Change) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := path + jsonName(f)
+		fa, fb := a.Field(i), b.Field(i)
+		switch fa.Kind() {
+		case reflect.Struct:
+			if ta, ok := fa.Interface().(time.Time); ok {
+				if !ta.Equal(fb.Interface().(time.Time)) {
+					*changes = append(*changes, Change{Field: name, From: fa.Interface(), To: fb.Interface()})
+				}
+				continue
+			}
+			diff(name+".", fa, fb, changes)
+		case reflect.Slice:
+			if fa.Len() != fb.Len() || fa.Type().Elem().Kind() != reflect.Struct {
+				if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+					*changes = append(*changes, Change{Field: name, From: fa.Interface(), To: fb.Interface()})
+				}
+				continue
+			}
+			for j := 0; j < fa.Len(); j++ {
+				diff(fmt.Sprintf("%s[%d].", name, j), fa.Index(j), fb.Index(j), changes)
+			}
+		case reflect.Ptr:
+			if !samePointee(fa, fb) {
+				*changes = append(*changes, Change{Field: name, From: fa.Interface(), To: fb.Interface()})
+			}
+		default:
+			if fa.Interface() != fb.Interface() {
+				*changes = append(*changes, Change{Field: name, From: fa.Interface(), To: fb.Interface()})
+			}
+		}
+	}
+}
+
+// samePointee tells whether a and b are both nil or point to equal values.
+func samePointee(a, b reflect.Value) bool {
+	if a.IsNil() || b.IsNil() {
+		return a.IsNil() == b.IsNil()
+	}
+	if ta, ok := a.Elem().Interface().(time.Time); ok {
+		return ta.Equal(b.Elem().Interface().(time.Time))
+	}
+	return reflect.DeepEqual(a.Elem().Interface(), b.Elem().Interface())
+}
+
+// Field returns the field of t at the path of a Change, e.g. parent.sev or
+// logs[2].date.
+func Field(t *Ticket, path string) (reflect.Value, error) {
+	v := reflect.ValueOf(t).Elem()
+	for _, segment := range strings.Split(path, ".") {
+		name, index := segment, -1
+		if i := strings.IndexByte(segment, '['); i >= 0 && strings.HasSuffix(segment, "]") {
+			n, err := strconv.Atoi(segment[i+1 : len(segment)-1])
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("%s: bad index in %q", path, segment)
+			}
+			name, index = segment[:i], n
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("%s: %s is not an object", path, name)
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" && jsonName(f) == name {
+				v, found = v.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("%s: no field %s", path, name)
+		}
+		if index >= 0 {
+			if v.Kind() != reflect.Slice || index >= v.Len() {
+				return reflect.Value{}, fmt.Errorf("%s: no item %d in %s", path, index, name)
+			}
+			v = v.Index(index)
+		}
+	}
+	return v, nil
+}
+
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package tickets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	at := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	later := at.Add(time.Hour)
+	base := Ticket{
+		Number: "T1",
+		State:  "Open",
+		Owner:  "jdoe",
+		Parent: TicketParent{Number: "P1"},
+		Logs:   []TicketLog{{Date: "2026-10-19 09:00:00", Info: "opened"}},
+	}
+	tests := []struct {
+		name   string
+		change func(t *Ticket)
+		want   []string
+	}{
+		{name: "equal", change: func(t *Ticket) {}},
+		{name: "field", change: func(t *Ticket) { t.State = "Closed" }, want: []string{"state"}},
+		{name: "json name", change: func(t *Ticket) { t.SubrootCause = "disk" }, want: []string{"subroot_cause"}},
+		{name: "nested field", change: func(t *Ticket) { t.Parent.Sev = "1" }, want: []string{"parent.sev"}},
+		{name: "list item", change: func(t *Ticket) { t.Logs = []TicketLog{{Date: "2026-10-19 09:00:00", Info: "edited"}} }, want: []string{"logs[0].info"}},
+		{
+			name:   "list length",
+			change: func(t *Ticket) { t.Logs = append(append([]TicketLog{}, t.Logs...), TicketLog{Info: "closed"}) },
+			want:   []string{"logs"},
+		},
+		{name: "iso fields", change: func(t *Ticket) { t.ISOOpened, t.Parent.ISOCreated = at, at }, want: []string{"isoopened", "parent.isocreated"}},
+		{name: "equal iso times", change: func(t *Ticket) { t.ISOOpened = time.Time{}.In(time.FixedZone("CDT", -5*3600)) }},
+		{name: "deletion", change: func(t *Ticket) { t.DeletedAt = &at }, want: []string{"deletedAt"}},
+		{name: "several", change: func(t *Ticket) { t.Owner, t.Parent.Number = "", "P2" }, want: []string{"parent.number", "owner"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base
+			tt.change(&b)
+			var fields []string
+			for _, c := range Diff(base, b) {
+				fields = append(fields, c.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("Diff fields %q, want %q", fields, tt.want)
+			}
+		})
+	}
+
+	t.Run("deletion times", func(t *testing.T) {
+		a, b := base, base
+		same := at.In(time.FixedZone("CDT", -5*3600))
+		a.DeletedAt, b.DeletedAt = &at, &same
+		if changes := Diff(a, b); len(changes) != 0 {
+			t.Errorf("equal times in two zones differ: %+v", changes)
+		}
+		b.DeletedAt = &later
+		if changes := Diff(a, b); len(changes) != 1 {
+			t.Errorf("Diff = %+v, want the deletion time", changes)
+		}
+	})
+}
+
+func TestSignificant(t *testing.T) {
+	tests := []struct {
+		changes []Change
+		want    bool
+	}{
+		{nil, false},
+		{[]Change{{Field: "lastmodified"}, {Field: "isolastmodified"}, {Field: "lastmodifiedby"}}, false},
+		{[]Change{{Field: "lastmodified"}, {Field: "state"}}, true},
+		{[]Change{{Field: "parent.lastmodified"}}, true},
+	}
+	for _, tt := range tests {
+		if got := Significant(tt.changes); got != tt.want {
+			t.Errorf("Significant(%+v) = %v, want %v", tt.changes, got, tt.want)
+		}
+	}
+}
+
+func TestClearServiceFields(t *testing.T) {
+	at := time.Now()
+	tk := Ticket{Number: "T1", Escalations: []Escalation{{Rule: "sev1"}}, DeletedAt: &at, DeletedBy: "jdoe", Version: 3}
+	tk.ClearServiceFields()
+	if !reflect.DeepEqual(tk, Ticket{Number: "T1"}) {
+		t.Errorf("ClearServiceFields left %+v", tk)
+	}
+}
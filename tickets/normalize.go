@@ -0,0 +1,133 @@
+package tickets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The timestamp fields of a ticket, as named in the layouts of a
+// LayoutNormalizer.
+const (
+	FieldOpened        = "opened"
+	FieldLastModified  = "lastmodified"
+	FieldClosed        = "closed"
+	FieldParentCreated = "parent.created"
+	FieldIthTime       = "ith.time"
+	FieldLogDate       = "logs.date"
+)
+
+// TimestampFields lists the timestamp fields of a ticket.
+var TimestampFields = []string{FieldOpened, FieldLastModified, FieldClosed, FieldParentCreated, FieldIthTime, FieldLogDate}
+
+// DefaultLayouts are the layouts the ticketing system writes its timestamps
+// in.
+func DefaultLayouts() map[string][]string {
+	return map[string][]string{
+		FieldOpened:        {"2006-01-02 15:04:05"},
+		FieldLastModified:  {"2006-01-02 15:04:05"},
+		FieldClosed:        {"01/02/2006 15:04"},
+		FieldParentCreated: {"2006-01-02 15:04:05"},
+		FieldIthTime:       {"2006-01-02 15:04:05"},
+		FieldLogDate:       {"2006-01-02 15:04:05"},
+	}
+}
+
+// Normalizer computes the ISO fields of a ticket from the timestamps written
+// by the ticketing system.
+type Normalizer interface {
+	Normalize(t *Ticket) error
+}
+
+// TimestampError tells which timestamp of a ticket could not be parsed.
+type TimestampError struct {
+	// Field is the JSON path of the timestamp, e.g. logs[2].date.
+	Field   string
+	Value   string
+	Layouts []string
+}
+
+func (e TimestampError) Error() string {
+	return fmt.Sprintf("%s: %q matches none of the layouts %s", e.Field, e.Value, strings.Join(e.Layouts, ", "))
+}
+
+// TimestampErrors lists every timestamp of a ticket that could not be parsed.
+type TimestampErrors []TimestampError
+
+func (e TimestampErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// LayoutNormalizer parses each timestamp with the first of the layouts of
+// its field that matches, in the timezone of the ticketing system, and
+// stores it in UTC.
+type LayoutNormalizer struct {
+	Location *time.Location
+	Layouts  map[string][]string
+}
+
+// NewNormalizer returns a LayoutNormalizer reading the timestamps in the
+// timezone named tz, e.g. "America/Chicago", with the layouts, the default
+// ones being used for the fields that are missing.
+func NewNormalizer(tz string, layouts map[string][]string) (*LayoutNormalizer, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	n := &LayoutNormalizer{Location: loc, Layouts: DefaultLayouts()}
+	for field, list := range layouts {
+		if _, ok := n.Layouts[field]; !ok {
+			return nil, fmt.Errorf("unknown timestamp field %q", field)
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("no layout for timestamp field %q", field)
+		}
+		n.Layouts[field] = list
+	}
+	return n, nil
+}
+
+// Normalize sets the ISO fields of t. The timestamps that are empty are
+// left zero; those that cannot be parsed are reported as TimestampErrors
+// and left zero as well.
+func (n *LayoutNormalizer) Normalize(t *Ticket) error {
+	var errs TimestampErrors
+	parse := func(dst *time.Time, field, path, value string) {
+		var err error
+		if *dst, err = n.parse(field, value); err != nil {
+			errs = append(errs, TimestampError{Field: path, Value: value, Layouts: n.Layouts[field]})
+		}
+	}
+	parse(&t.ISOOpened, FieldOpened, "opened", t.Opened)
+	parse(&t.ISOLastModified, FieldLastModified, "lastmodified", t.LastModified)
+	parse(&t.ISOClosed, FieldClosed, "closed", t.Closed)
+	parse(&t.Parent.ISOCreated, FieldParentCreated, "parent.created", t.Parent.Created)
+	for i := range t.Ith {
+		parse(&t.Ith[i].ISODate, FieldIthTime, fmt.Sprintf("ith[%d].time", i), t.Ith[i].Time)
+	}
+	for i := range t.Logs {
+		parse(&t.Logs[i].ISODate, FieldLogDate, fmt.Sprintf("logs[%d].date", i), t.Logs[i].Date)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (n *LayoutNormalizer) parse(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	err := fmt.Errorf("no layout for %s", field)
+	for _, layout := range n.Layouts[field] {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, n.Location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, err
+}
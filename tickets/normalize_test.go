@@ -0,0 +1,138 @@
+package tickets
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewNormalizer(t *testing.T) {
+	tests := []struct {
+		name    string
+		tz      string
+		layouts map[string][]string
+		wantErr bool
+	}{
+		{name: "defaults", tz: "America/Chicago"},
+		{name: "override", tz: "UTC", layouts: map[string][]string{FieldClosed: {time.RFC3339}}},
+		{name: "unknown timezone", tz: "Mars/Olympus", wantErr: true},
+		{name: "unknown field", tz: "UTC", layouts: map[string][]string{"resolved": {time.RFC3339}}, wantErr: true},
+		{name: "no layout", tz: "UTC", layouts: map[string][]string{FieldOpened: {}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNormalizer(tt.tz, tt.layouts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNormalizer error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, field := range TimestampFields {
+				want := DefaultLayouts()[field]
+				if list, ok := tt.layouts[field]; ok {
+					want = list
+				}
+				if !reflect.DeepEqual(n.Layouts[field], want) {
+					t.Errorf("layouts of %s = %q, want %q", field, n.Layouts[field], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	n, err := NewNormalizer("America/Chicago", map[string][]string{
+		FieldOpened: {"2006-01-02 15:04:05", "01/02/2006 15:04"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name   string
+		ticket Ticket
+		want   Ticket
+		errs   []string
+	}{
+		{
+			name:   "empty timestamps stay zero",
+			ticket: Ticket{},
+			want:   Ticket{},
+		},
+		{
+			name: "daylight saving time",
+			ticket: Ticket{
+				Opened:       "2026-07-01 09:00:00",
+				LastModified: "2026-12-01 09:00:00",
+				Closed:       "12/01/2026 10:30",
+				Parent:       TicketParent{Created: "2026-06-30 23:30:00"},
+			},
+			want: Ticket{
+				Opened:          "2026-07-01 09:00:00",
+				ISOOpened:       utc("2026-07-01 14:00"),
+				LastModified:    "2026-12-01 09:00:00",
+				ISOLastModified: utc("2026-12-01 15:00"),
+				Closed:          "12/01/2026 10:30",
+				ISOClosed:       utc("2026-12-01 16:30"),
+				Parent:          TicketParent{Created: "2026-06-30 23:30:00", ISOCreated: utc("2026-07-01 04:30")},
+			},
+		},
+		{
+			name:   "second layout",
+			ticket: Ticket{Opened: "07/01/2026 09:00"},
+			want:   Ticket{Opened: "07/01/2026 09:00", ISOOpened: utc("2026-07-01 14:00")},
+		},
+		{
+			name: "history and logs",
+			ticket: Ticket{
+				Ith:  []ITH{{Time: "2026-07-01 09:00:00"}},
+				Logs: []TicketLog{{Date: "2026-07-01 09:05:00"}, {Date: "2026-07-01 09:10:00"}},
+			},
+			want: Ticket{
+				Ith:  []ITH{{Time: "2026-07-01 09:00:00", ISODate: utc("2026-07-01 14:00")}},
+				Logs: []TicketLog{{Date: "2026-07-01 09:05:00", ISODate: utc("2026-07-01 14:05")}, {Date: "2026-07-01 09:10:00", ISODate: utc("2026-07-01 14:10")}},
+			},
+		},
+		{
+			name: "every unparsable timestamp is reported",
+			ticket: Ticket{
+				Opened: "yesterday",
+				Closed: "2026-07-01 09:00:00",
+				Logs:   []TicketLog{{Date: "2026-07-01 09:05:00"}, {Date: "07/01/2026"}},
+			},
+			want: Ticket{
+				Opened: "yesterday",
+				Closed: "2026-07-01 09:00:00",
+				Logs:   []TicketLog{{Date: "2026-07-01 09:05:00", ISODate: utc("2026-07-01 14:05")}, {Date: "07/01/2026"}},
+			},
+			errs: []string{"opened", "closed", "logs[1].date"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.ticket
+			err := n.Normalize(&got)
+			var fields []string
+			if errs, ok := err.(TimestampErrors); ok {
+				for _, e := range errs {
+					fields = append(fields, e.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("Normalize returned %T, want TimestampErrors", err)
+			}
+			if !reflect.DeepEqual(fields, tt.errs) {
+				t.Errorf("errors on %q, want %q", fields, tt.errs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalize = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package tickets
+
+import "strings"
+
+// SevLevel reduces the spellings of a severity the ticketing system uses
+// ("1", "Sev1", "SEV 1") to its digits.
+func SevLevel(sev string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.Replace(sev, " ", "", -1)), "sev")
+}
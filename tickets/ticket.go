@@ -4,45 +4,70 @@ import "time"
 
 type ITH struct {
 	State      string    `json:"state"`
-	Time       string    `json:"time"`
+	Time       string    `json:"time" validate:"required"`
 	ISODate    time.Time `json:"isodate"`
 	ModifiedBy string    `json:"modifiedby"`
 	Activity   string    `json:"activity"`
 }
 type TicketLog struct {
-	Date    string    `json:"date"`
+	Date    string    `json:"date" validate:"required"`
 	ISODate time.Time `json:"isodate"`
 	Info    string    `json:"info"`
 	User    string    `json:"user"`
 }
+
+// Escalation is a step of an escalation policy that fired for the ticket.
+type Escalation struct {
+	Rule     string    `json:"rule"`
+	Step     int       `json:"step"`
+	Since    time.Time `json:"since"`
+	Time     time.Time `json:"time"`
+	Notified []string  `json:"notified"`
+}
 type TicketParent struct {
 	Status     string    `json:"status"`
-	Sev        string    `json:"sev"`
+	Sev        string    `json:"sev" validate:"sev"`
 	Number     string    `json:"number"`
 	Created    string    `json:"created"`
-	ISOCreated time.Time `json:"isocreted"`
+	ISOCreated time.Time `json:"isocreated"`
 }
 
 type Ticket struct {
-	Sev             string       `json:"sev"`
+	Sev             string       `json:"sev" validate:"required,sev"`
 	SubrootCause    string       `json:"subroot_cause"`
-	Opened          string       `json:"opened"`
+	Opened          string       `json:"opened" validate:"required"`
 	ISOOpened       time.Time    `json:"isoopened"`
 	Parent          TicketParent `json:"parent"`
 	Handover        string       `json:"handover"`
 	Abstract        string       `json:"abstract"`
-	Number          string       `json:"number"`
+	Number          string       `json:"number" validate:"required"`
 	LastModifiedBy  string       `json:"lastmodifiedby"`
-	State           string       `json:"state"`
+	State           string       `json:"state" validate:"required"`
 	LastModified    string       `json:"lastmodified"`
 	ISOLastModified time.Time    `json:"isolastmodified"`
 	Role            string       `json:"role"`
 	Dispatch        string       `json:"dispatch"`
-	ISOClosed       time.Time    `json:isoclosed`
+	ISOClosed       time.Time    `json:"isoclosed"`
 	Closed          string       `json:"closed"`
 	Owner           string       `json:"owner"`
 	RootCause       string       `json:"rootcause"`
 	Restored        string       `json:"restored"`
 	Ith             []ITH        `json:"ith"`
 	Logs            []TicketLog  `json:"logs"`
+	Escalations     []Escalation `json:"escalations"`
+	// DeletedAt and DeletedBy mark a ticket deleted until it is restored
+	// or purged.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
+	// Version counts the writes to the ticket, so that a write computed
+	// from the ticket read applies to that state only.
+	Version int `json:"version" bson:"version"`
+}
+
+// ClearServiceFields clears the fields written by the service only, which a
+// client or the source cannot set on a new ticket.
+func (t *Ticket) ClearServiceFields() {
+	t.Escalations = nil
+	t.DeletedAt, t.DeletedBy = nil, ""
+	t.Version = 0
 }
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/microservices/api/metrics"
+	"github.com/microservices/api/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	mgo "gopkg.in/mgo.v2"
+)
+
+const tracerName = "github.com/microservices/api"
+
+// Setup installs the global tracer provider exporting to exporter: "otlp"
+// (configured by the standard OTEL_EXPORTER_OTLP_* variables), "stdout", or
+// "none"/"" to only propagate the trace context. The returned function
+// flushes and stops the exporter.
+func Setup(service, exporter string) (func(context.Context) error, error) {
+	setPropagator()
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exp, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return SetupWith(service, exp).Shutdown, nil
+}
+
+// SetupWith installs a tracer provider batching the spans to exp, e.g. a
+// tracetest.InMemoryExporter in tests, which ForceFlush the provider before
+// reading the spans.
+func SetupWith(service string, exp sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	setPropagator()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(service),
+			semconv.ServiceVersion(version.Release),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider
+}
+
+// setPropagator reads and writes the W3C traceparent and baggage headers.
+func setPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Start starts a span named name as a child of the span of ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span per request, named after the route
+// template, continuing the trace of the W3C traceparent header if present.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := metrics.Route(r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		rec := metrics.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.Status))
+		if rec.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status))
+		}
+	})
+}
+
+// Mongo starts the client span of an operation on a collection.
+func Mongo(ctx context.Context, collection, operation string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "mongo "+operation+" "+collection,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMongoDB,
+			semconv.DBCollectionName(collection),
+			semconv.DBOperationName(operation),
+		))
+}
+
+// MongoOp runs op, an operation on the collection c, in a child span of ctx
+// and records its latency. A document not found is not an error of the span.
+func MongoOp(ctx context.Context, c *mgo.Collection, operation string, op func() error) error {
+	_, span := Mongo(ctx, c.Name, operation)
+	start := time.Now()
+	err := op()
+	metrics.ObserveMongo(c.Name, operation, start)
+	if err == mgo.ErrNotFound {
+		span.End()
+	} else {
+		End(span, err)
+	}
+	return err
+}
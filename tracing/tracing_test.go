@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// record installs a provider exporting to memory and returns the spans
+// ended by f.
+func record(t *testing.T, f func()) tracetest.SpanStubs {
+	exp := tracetest.NewInMemoryExporter()
+	provider := SetupWith("api-test", exp)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	f()
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return exp.GetSpans()
+}
+
+func attr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+		span   string
+		code   codes.Code
+	}{
+		{name: "route template", path: "/api/ticket/T1", status: http.StatusOK, span: "GET /api/ticket/{number}", code: codes.Unset},
+		{name: "client error", path: "/api/ticket/T1", status: http.StatusNotFound, span: "GET /api/ticket/{number}", code: codes.Unset},
+		{name: "server error", path: "/api/ticket/T1", status: http.StatusBadGateway, span: "GET /api/ticket/{number}", code: codes.Error},
+		{name: "unmatched", path: "/nowhere", status: http.StatusNotFound, span: "GET unmatched", code: codes.Unset},
+		{
+			name:   "continued trace",
+			path:   "/api/ticket/T1",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			status: http.StatusOK,
+			span:   "GET /api/ticket/{number}",
+			code:   codes.Unset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mux.NewRouter()
+			r.Use(Middleware)
+			r.HandleFunc("/api/ticket/{number}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			})
+			r.NotFoundHandler = Middleware(http.NotFoundHandler())
+
+			spans := record(t, func() {
+				req := httptest.NewRequest("GET", tt.path, nil)
+				if tt.header != "" {
+					req.Header.Set("traceparent", tt.header)
+				}
+				r.ServeHTTP(httptest.NewRecorder(), req)
+			})
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tt.span || span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span %q of kind %v, want server span %q", span.Name, span.SpanKind, tt.span)
+			}
+			if got := attr(span, "http.response.status_code").AsInt64(); got != int64(tt.status) {
+				t.Errorf("status attribute %d, want %d", got, tt.status)
+			}
+			if span.Status.Code != tt.code {
+				t.Errorf("span status %v, want %v", span.Status.Code, tt.code)
+			}
+			if tt.header != "" && span.Parent.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("span is not part of the trace of the traceparent header: %v", span.Parent.TraceID())
+			}
+		})
+	}
+}
+
+func TestMongoOp(t *testing.T) {
+	failure := errors.New("socket closed")
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{name: "success", err: nil, code: codes.Unset},
+		{name: "not found", err: mgo.ErrNotFound, code: codes.Unset},
+		{name: "failure", err: failure, code: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mgo.Collection{Name: "tickets", FullName: "info.tickets"}
+			var err error
+			spans := record(t, func() {
+				err = MongoOp(context.Background(), c, "find", func() error { return tt.err })
+			})
+			if err != tt.err {
+				t.Errorf("MongoOp returned %v, want %v", err, tt.err)
+			}
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != "mongo find tickets" || span.SpanKind != trace.SpanKindClient {
+				t.Errorf("span %q of kind %v, want client span %q", span.Name, span.SpanKind, "mongo find tickets")
+			}
+			if got := attr(span, "db.collection.name").AsString(); got != "tickets" {
+				t.Errorf("collection attribute %q, want tickets", got)
+			}
+			if span.Status.Code != tt.code {
+				t.Errorf("span status %v, want %v", span.Status.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestStartIsChildSpan(t *testing.T) {
+	spans := record(t, func() {
+		ctx, parent := Start(context.Background(), "parent")
+		_, child := Start(ctx, "child", attribute.String("number", "T1"))
+		End(child, errors.New("boom"))
+		parent.End()
+	})
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	child, parent := spans[0], spans[1]
+	if child.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Error("child span is not a child of the parent span")
+	}
+	if child.Status.Code != codes.Error || len(child.Events) == 0 {
+		t.Errorf("child span status %v with %d events, want the error recorded", child.Status.Code, len(child.Events))
+	}
+}
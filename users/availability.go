@@ -0,0 +1,33 @@
+package user
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Availability marks a user as unavailable (vacation, sick leave, training...)
+// for the period [From, To).
+type Availability struct {
+	Id     bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	UserID string        `json:"user_id" bson:"user_id"`
+	From   time.Time     `json:"from"`
+	To     time.Time     `json:"to"`
+	Reason string        `json:"reason"`
+}
+
+// Overlaps reports whether the record intersects the period [from, to).
+func (a Availability) Overlaps(from, to time.Time) bool {
+	return a.From.Before(to) && from.Before(a.To)
+}
+
+// Away reports whether the user uid is unavailable at some point of [from, to)
+// according to records.
+func Away(records []Availability, uid string, from, to time.Time) bool {
+	for _, a := range records {
+		if a.UserID == uid && a.Overlaps(from, to) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverlaps(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	hour := time.Hour
+	leave := Availability{UserID: "jdoe", From: at, To: at.Add(8 * hour)}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     bool
+	}{
+		{"inside", at.Add(hour), at.Add(2 * hour), true},
+		{"around", at.Add(-hour), at.Add(9 * hour), true},
+		{"over the start", at.Add(-hour), at.Add(hour), true},
+		{"over the end", at.Add(7 * hour), at.Add(9 * hour), true},
+		{"same period", at, at.Add(8 * hour), true},
+		{"instant at the start", at, at.Add(time.Nanosecond), true},
+		{"ends at the start", at.Add(-hour), at, false},
+		{"starts at the end", at.Add(8 * hour), at.Add(9 * hour), false},
+		{"before", at.Add(-2 * hour), at.Add(-hour), false},
+		{"after", at.Add(9 * hour), at.Add(10 * hour), false},
+	}
+	for _, tt := range tests {
+		if got := leave.Overlaps(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: Overlaps(%s, %s) = %v, want %v", tt.name, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAway(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	records := []Availability{
+		{UserID: "jdoe", From: at, To: at.Add(day)},
+		{UserID: "asmith", From: at.Add(2 * day), To: at.Add(3 * day)},
+		{UserID: "jdoe", From: at.Add(5 * day), To: at.Add(6 * day)},
+	}
+	tests := []struct {
+		uid      string
+		from, to time.Time
+		want     bool
+	}{
+		{"jdoe", at.Add(time.Hour), at.Add(2 * time.Hour), true},
+		{"jdoe", at.Add(day), at.Add(2 * day), false},
+		{"jdoe", at.Add(4 * day), at.Add(5*day + time.Minute), true},
+		{"asmith", at, at.Add(day), false},
+		{"asmith", at.Add(2 * day), at.Add(2*day + time.Nanosecond), true},
+		{"bking", at, at.Add(10 * day), false},
+	}
+	for _, tt := range tests {
+		if got := Away(records, tt.uid, tt.from, tt.to); got != tt.want {
+			t.Errorf("Away(%s, %s, %s) = %v, want %v", tt.uid, tt.from, tt.to, got, tt.want)
+		}
+	}
+	if Away(nil, "jdoe", at, at.Add(day)) {
+		t.Error("Away without records")
+	}
+}
@@ -0,0 +1,18 @@
+package user
+
+// Rotation remembers whose turn it is in the rotation pool of a role. The
+// general pool is tracked by the Current flag of User instead.
+type Rotation struct {
+	Role    string `json:"role" bson:"_id"`
+	Current string `json:"current" bson:"current"`
+}
+
+// HasRole reports whether the user can take tickets of the given role.
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,19 @@
 package user
 
+import "time"
+
 type User struct {
-	Name      string `json:"name"`
-	Is_Active bool   `json:"is_active"`
-	Real_Name string `json:"real_name"`
-	Current   bool   `json:"current"`
-	Is_Admin  bool   `json:"is_admin"`
-	ID        string `json:"id"`
-	Engineer  bool   `json:"engineer"`
-	Attuid    string `json:"attuid"`
+	Name     string   `json:"name" validate:"required"`
+	Active   bool     `json:"is_active" bson:"active"`
+	RealName string   `json:"real_name" bson:"realname"`
+	Current  bool     `json:"current"`
+	IsAdmin  bool     `json:"is_admin" bson:"admin"`
+	ID       string   `json:"id" validate:"required,pattern=^[A-Za-z0-9._-]+$"`
+	Engineer bool     `json:"engineer"`
+	Attuid   string   `json:"attuid" validate:"pattern=^[a-zA-Z]{2}[0-9]{3}[a-zA-Z0-9]$"`
+	Roles    []string `json:"roles"`
+	// DeletedAt and DeletedBy mark a user deleted until it is restored or
+	// purged.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
 }
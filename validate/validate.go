@@ -0,0 +1,152 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microservices/api/apierror"
+	ticket "github.com/microservices/api/tickets"
+)
+
+// Struct checks the string fields of the struct pointed to by v against the
+// rules of their validate tags, going down nested structs and slices, and
+// returns the invalid fields named by their JSON path. The rules, separated
+// by commas, are:
+//
+//	required          the value must not be empty
+//	oneof=a|b|c       the value must be one of the listed ones
+//	time=<layout>     the value must be a time written in the Go layout
+//	pattern=<regexp>  the value must match the regular expression
+//	sev               the value must be a severity from 1 to 5, spelled
+//	                  "1", "Sev1" or "SEV 1" as the ticketing system does
+//
+// Empty values only fail the required rule.
+func Struct(v interface{}) []apierror.FieldError {
+	var errs []apierror.FieldError
+	walk("", reflect.Indirect(reflect.ValueOf(v)), &errs)
+	return errs
+}
+
+func walk(path string, v reflect.Value, errs *[]apierror.FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := path + jsonName(f)
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Struct:
+			if _, ok := fv.Interface().(time.Time); !ok {
+				walk(name+".", fv, errs)
+			}
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() == reflect.Struct {
+				for j := 0; j < fv.Len(); j++ {
+					walk(fmt.Sprintf("%s[%d].", name, j), fv.Index(j), errs)
+				}
+			}
+		case reflect.String:
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if msg := check(tag, fv.String()); msg != "" {
+					*errs = append(*errs, apierror.FieldError{Field: name, Message: msg})
+				}
+			}
+		}
+	}
+}
+
+// jsonName returns the key of the field in JSON.
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// check returns why value breaks the rules of tag, or "" when it does not.
+func check(tag, value string) string {
+	for _, rule := range splitRules(tag) {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		if name == "required" {
+			if strings.TrimSpace(value) == "" {
+				return "is required"
+			}
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		switch name {
+		case "oneof":
+			allowed := strings.Split(arg, "|")
+			if !contains(allowed, value) {
+				return "must be one of " + strings.Join(allowed, ", ")
+			}
+		case "time":
+			if _, err := time.Parse(arg, value); err != nil {
+				return "must be a time like " + strconv.Quote(arg)
+			}
+		case "pattern":
+			if !pattern(arg).MatchString(value) {
+				return "has an invalid format"
+			}
+		case "sev":
+			if !contains([]string{"1", "2", "3", "4", "5"}, ticket.SevLevel(value)) {
+				return "must be a severity from 1 to 5"
+			}
+		default:
+			panic("validate: unknown rule " + rule)
+		}
+	}
+	return ""
+}
+
+// splitRules splits tag on the commas that start a rule, so that the time
+// layouts and patterns may contain commas.
+func splitRules(tag string) []string {
+	var rules []string
+	for _, part := range strings.Split(tag, ",") {
+		if n := len(rules); n > 0 && !isRule(part) {
+			rules[n-1] += "," + part
+			continue
+		}
+		rules = append(rules, part)
+	}
+	return rules
+}
+
+func isRule(s string) bool {
+	name := strings.SplitN(s, "=", 2)[0]
+	return contains([]string{"required", "oneof", "time", "pattern", "sev"}, name)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var patterns sync.Map
+
+// pattern compiles expr once.
+func pattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	patterns.Store(expr, re)
+	return re
+}
@@ -0,0 +1,123 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/microservices/api/apierror"
+	ticket "github.com/microservices/api/tickets"
+)
+
+func TestSplitRules(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"required", []string{"required"}},
+		{"required,oneof=a|b", []string{"required", "oneof=a|b"}},
+		{"time=Jan 2, 2006", []string{"time=Jan 2, 2006"}},
+		{"required,time=Jan 2, 2006,pattern=^[a-z]{1,3}$", []string{"required", "time=Jan 2, 2006", "pattern=^[a-z]{1,3}$"}},
+		{"pattern=^a{2,}$,required", []string{"pattern=^a{2,}$", "required"}},
+		{"required,sev", []string{"required", "sev"}},
+	}
+	for _, tt := range tests {
+		if got := splitRules(tt.tag); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitRules(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		tag   string
+		value string
+		want  string
+	}{
+		{"required", "x", ""},
+		{"required", "", "is required"},
+		{"required", "  ", "is required"},
+		{"oneof=a|b", "b", ""},
+		{"oneof=a|b", "c", "must be one of a, b"},
+		{"oneof=a|b", "", ""},
+		{"required,oneof=a|b", "", "is required"},
+		{"time=2006-01-02", "2026-10-19", ""},
+		{"time=2006-01-02", "19/10/2026", `must be a time like "2006-01-02"`},
+		{"time=Jan 2, 2006", "Oct 19, 2026", ""},
+		{"pattern=^[a-z]{2}[0-9]{3}$", "jd123", ""},
+		{"pattern=^[a-z]{2}[0-9]{3}$", "jd12", "has an invalid format"},
+		{"sev", "1", ""},
+		{"sev", "5", ""},
+		{"sev", "Sev1", ""},
+		{"sev", "SEV 2", ""},
+		{"sev", "sev 3", ""},
+		{"sev", "6", "must be a severity from 1 to 5"},
+		{"sev", "Sev", "must be a severity from 1 to 5"},
+		{"sev", "", ""},
+		{"required,sev", "", "is required"},
+	}
+	for _, tt := range tests {
+		if got := check(tt.tag, tt.value); got != tt.want {
+			t.Errorf("check(%q, %q) = %q, want %q", tt.tag, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("check did not panic on an unknown rule")
+		}
+	}()
+	check("required,maxlen=3", "abc")
+}
+
+func TestStruct(t *testing.T) {
+	type inner struct {
+		Code string `json:"code" validate:"required"`
+	}
+	type outer struct {
+		Name    string  `json:"name" validate:"required"`
+		Kind    string  `validate:"oneof=a|b"`
+		Inner   inner   `json:"inner"`
+		Items   []inner `json:"items"`
+		Tags    []string
+		private string
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want []apierror.FieldError
+	}{
+		{
+			name: "valid",
+			v:    &outer{Name: "n", Kind: "a", Inner: inner{Code: "c"}, Items: []inner{{Code: "c"}}},
+		},
+		{
+			name: "nested and listed fields are named by their path",
+			v:    &outer{Kind: "z", Items: []inner{{Code: "c"}, {}}},
+			want: []apierror.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "Kind", Message: "must be one of a, b"},
+				{Field: "inner.code", Message: "is required"},
+				{Field: "items[1].code", Message: "is required"},
+			},
+		},
+		{
+			name: "ticket with the spellings of the ticketing system",
+			v: &ticket.Ticket{Number: "T1", State: "Queued", Opened: "10/19/2026 09:00", Sev: "Sev1",
+				Parent: ticket.TicketParent{Sev: "SEV 2"}},
+		},
+		{
+			name: "ticket with an unknown severity",
+			v:    &ticket.Ticket{Number: "T1", State: "Queued", Opened: "10/19/2026 09:00", Sev: "urgent"},
+			want: []apierror.FieldError{{Field: "sev", Message: "must be a severity from 1 to 5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Struct(tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/logs"
+	"github.com/microservices/api/store"
+	log "github.com/sirupsen/logrus"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Dispatcher delivers the events to the subscribed webhooks. The deliveries
+// are queued in Mongo when the event is published and worked off by every
+// replica running the dispatcher, so that none is lost when a receiver is
+// slow or down, or when the service restarts.
+type Dispatcher struct {
+	session *mgo.Session
+	// Client is used to POST the payloads.
+	Client *http.Client
+	// MaxAttempts is the number of tries before a delivery is given up.
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles on every retry.
+	Backoff time.Duration
+	// Workers is the number of deliveries tried at the same time.
+	Workers int
+	// Poll is the wait between two looks at an empty queue.
+	Poll time.Duration
+	// Lease is how long a replica has to try a delivery before another one
+	// may take it over.
+	Lease time.Duration
+}
+
+func NewDispatcher(s *mgo.Session) *Dispatcher {
+	return &Dispatcher{
+		session:     s,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		Workers:     4,
+		Poll:        time.Second,
+		Lease:       time.Minute,
+	}
+}
+
+// Sign returns the value of the signature header for body sent at timestamp
+// (the value of the timestamp header): the hex encoded HMAC-SHA256 of
+// timestamp + "." + body keyed with the subscription secret. Receivers
+// should reject old timestamps, so that a captured delivery cannot be
+// replayed.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue queues the delivery of e to the subscriptions that want it. It is
+// meant to be hooked on the event bus.
+func (d *Dispatcher) Enqueue(e events.Event) {
+	subs, err := d.subscriptions(e.Type)
+	if err != nil {
+		logs.Component("webhooks").WithField("event", e.ID).Error("Can't get subscriptions: ", err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		logs.Component("webhooks").WithField("event", e.ID).Error("Can't encode event: ", err)
+		return
+	}
+	session := d.session.Copy()
+	defer session.Close()
+	c := store.WebhookQueue(session)
+	now := time.Now().UTC()
+	for _, sub := range subs {
+		p := Pending{
+			Id:           bson.NewObjectId(),
+			Subscription: sub.Id,
+			Event:        e.Type,
+			EventID:      e.ID,
+			Body:         body,
+			Due:          now,
+		}
+		if err := c.Insert(p); err != nil {
+			logs.Component("webhooks").WithFields(log.Fields{"event": e.ID, "url": sub.URL}).Error("Can't queue delivery: ", err)
+		}
+	}
+}
+
+// Run works off the queued deliveries until stop is closed.
+func (d *Dispatcher) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(stop)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) work(stop <-chan struct{}) {
+	for {
+		p, ok := d.next()
+		if !ok {
+			select {
+			case <-stop:
+				return
+			case <-time.After(d.Poll):
+			}
+			continue
+		}
+		d.deliver(p)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// next takes the lease of the oldest due delivery, if any.
+func (d *Dispatcher) next() (Pending, bool) {
+	session := d.session.Copy()
+	defer session.Close()
+	now := time.Now().UTC()
+	var p Pending
+	_, err := store.WebhookQueue(session).Find(bson.M{"due": bson.M{"$lte": now}}).Sort("due").Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"due": now.Add(d.Lease)}},
+		ReturnNew: false,
+	}, &p)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			logs.Component("webhooks").Error("Can't read queue: ", err)
+		}
+		return p, false
+	}
+	return p, true
+}
+
+func (d *Dispatcher) subscriptions(t string) ([]Subscription, error) {
+	session := d.session.Copy()
+	defer session.Close()
+	var subs []Subscription
+	err := store.Webhooks(session).Find(bson.M{"active": true}).All(&subs)
+	var wanted []Subscription
+	for _, s := range subs {
+		if s.Wants(t) {
+			wanted = append(wanted, s)
+		}
+	}
+	return wanted, err
+}
+
+// deliver makes one attempt of the queued delivery p and records it in the
+// delivery log. A failed attempt is queued again with exponential backoff
+// until MaxAttempts; a delivery to a subscription deleted or deactivated
+// meanwhile is dropped.
+func (d *Dispatcher) deliver(p Pending) {
+	session := d.session.Copy()
+	defer session.Close()
+	queue := store.WebhookQueue(session)
+
+	var sub Subscription
+	err := store.Webhooks(session).FindId(p.Subscription).One(&sub)
+	if err != nil && err != mgo.ErrNotFound {
+		logs.Component("webhooks").WithField("event", p.EventID).Error("Can't get subscription: ", err)
+		return
+	}
+	if err == mgo.ErrNotFound || !sub.Active {
+		d.done(queue, p)
+		return
+	}
+
+	p.Attempt++
+	delivery := d.post(sub, p)
+	d.record(delivery)
+	if delivery.Success {
+		d.done(queue, p)
+		return
+	}
+	if p.Attempt >= d.MaxAttempts {
+		logs.Component("webhooks").WithFields(log.Fields{"event": p.EventID, "url": sub.URL}).Warn("Gave up delivery")
+		d.done(queue, p)
+		return
+	}
+	due := time.Now().UTC().Add(d.Backoff << uint(p.Attempt-1))
+	err = queue.UpdateId(p.Id, bson.M{"$set": bson.M{"attempt": p.Attempt, "due": due}})
+	if err != nil {
+		logs.Component("webhooks").WithField("event", p.EventID).Error("Can't requeue delivery: ", err)
+	}
+}
+
+func (d *Dispatcher) done(queue *mgo.Collection, p Pending) {
+	if err := queue.RemoveId(p.Id); err != nil && err != mgo.ErrNotFound {
+		logs.Component("webhooks").WithField("event", p.EventID).Error("Can't dequeue delivery: ", err)
+	}
+}
+
+func (d *Dispatcher) post(sub Subscription, p Pending) Delivery {
+	delivery := Delivery{
+		Id:           bson.NewObjectId(),
+		Subscription: sub.Id,
+		Event:        p.Event,
+		EventID:      p.EventID,
+		Attempt:      p.Attempt,
+		Time:         time.Now().UTC(),
+	}
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(p.Body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, p.Event)
+	req.Header.Set(DeliveryHeader, p.EventID)
+	timestamp := strconv.FormatInt(delivery.Time.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, p.Body))
+
+	resp, err := d.Client.Do(req)
+	delivery.Duration = time.Since(delivery.Time)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+	delivery.Status = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return delivery
+}
+
+func (d *Dispatcher) record(delivery Delivery) {
+	session := d.session.Copy()
+	defer session.Close()
+	if err := store.WebhookDeliveries(session).Insert(delivery); err != nil {
+		logs.Component("webhooks").WithField("event", delivery.EventID).Error("Can't record delivery: ", err)
+	}
+}
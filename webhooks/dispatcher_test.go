@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microservices/api/events"
+	"github.com/microservices/api/store"
+	"github.com/microservices/api/store/storetest"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// receiver records the deliveries it gets and fails the first ones.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	rc.times = append(rc.times, time.Now())
+	if len(rc.requests) <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestPostSigns(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	d := NewDispatcher(nil)
+	d.Client = srv.Client()
+	sub := Subscription{Id: bson.NewObjectId(), URL: srv.URL, Secret: "s3cret", Active: true}
+	p := Pending{Event: events.TicketCreated, EventID: "e1-1", Body: []byte(`{"type":"ticket.created"}`), Attempt: 1}
+
+	delivery := d.post(sub, p)
+	if !delivery.Success || delivery.Status != http.StatusOK || delivery.Attempt != 1 {
+		t.Fatalf("delivery %+v, want a success", delivery)
+	}
+	r := rc.requests[0]
+	timestamp := r.Header.Get(TimestampHeader)
+	if sec, err := strconv.ParseInt(timestamp, 10, 64); err != nil || sec != delivery.Time.Unix() {
+		t.Errorf("timestamp %q, want the delivery time %d", timestamp, delivery.Time.Unix())
+	}
+	if got, want := r.Header.Get(SignatureHeader), Sign(sub.Secret, timestamp, rc.bodies[0]); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if got := r.Header.Get(SignatureHeader); got == Sign("other", timestamp, rc.bodies[0]) || got == Sign(sub.Secret, "0", rc.bodies[0]) {
+		t.Error("the signature does not depend on the secret and the timestamp")
+	}
+	for h, want := range map[string]string{EventHeader: p.Event, DeliveryHeader: p.EventID, "Content-Type": "application/json"} {
+		if got := r.Header.Get(h); got != want {
+			t.Errorf("%s %q, want %q", h, got, want)
+		}
+	}
+
+	rc.failures = 2
+	if delivery := d.post(sub, p); delivery.Success || delivery.Status != http.StatusServiceUnavailable || delivery.Error == "" {
+		t.Errorf("delivery %+v, want a failure", delivery)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     []bool
+	}{
+		{name: "delivered", want: []bool{true}},
+		{name: "retried", failures: 2, want: []bool{false, false, true}},
+		{name: "given up", failures: 5, want: []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := storetest.Session(t)
+			rc := &receiver{failures: tt.failures}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+			d := NewDispatcher(session)
+			d.Client = srv.Client()
+			d.MaxAttempts = 3
+			d.Backoff = 50 * time.Millisecond
+			sub := Subscription{Id: bson.NewObjectId(), URL: srv.URL, Secret: "s3cret", Events: []string{events.TicketCreated}, Active: true}
+			if err := store.Webhooks(session).Insert(sub); err != nil {
+				t.Fatal(err)
+			}
+
+			d.Enqueue(events.Event{ID: "e1-1", Type: events.TicketUpdated})
+			d.Enqueue(events.Event{ID: "e1-2", Type: events.TicketCreated})
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if n, err := store.WebhookQueue(session).Count(); err != nil || n == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("the delivery is still queued")
+				}
+				if p, ok := d.next(); ok {
+					d.deliver(p)
+				} else {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+
+			var log []Delivery
+			if err := store.WebhookDeliveries(session).Find(nil).Sort("attempt").All(&log); err != nil {
+				t.Fatal(err)
+			}
+			if len(log) != len(tt.want) || len(rc.requests) != len(tt.want) {
+				t.Fatalf("%d deliveries logged and %d received, want %d", len(log), len(rc.requests), len(tt.want))
+			}
+			for i, delivery := range log {
+				if delivery.Attempt != i+1 || delivery.Success != tt.want[i] || delivery.EventID != "e1-2" || delivery.Subscription != sub.Id {
+					t.Errorf("delivery %d: %+v", i, delivery)
+				}
+				if i > 0 {
+					if wait := rc.times[i].Sub(rc.times[i-1]); wait < d.Backoff<<uint(i-1) {
+						t.Errorf("attempt %d came %v after the previous one, want a backoff of %v", i+1, wait, d.Backoff<<uint(i-1))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package webhooks
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Subscription asks for the events of the listed types (all events when
+// Events is empty) to be POSTed to URL, signed with Secret.
+type Subscription struct {
+	Id      bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	URL     string        `json:"url"`
+	Secret  string        `json:"secret,omitempty"`
+	Events  []string      `json:"events"`
+	Active  bool          `json:"active"`
+	Created time.Time     `json:"created"`
+}
+
+// Wants reports whether the subscription is interested in events of type t.
+func (s Subscription) Wants(t string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one attempt to deliver an event to a subscription.
+type Delivery struct {
+	Id           bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	Subscription bson.ObjectId `json:"subscription"`
+	Event        string        `json:"event"`
+	EventID      string        `json:"event_id" bson:"event_id"`
+	Attempt      int           `json:"attempt"`
+	Time         time.Time     `json:"time"`
+	Duration     time.Duration `json:"duration"`
+	Status       int           `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Success      bool          `json:"success"`
+}
+
+// Pending is a delivery waiting in the queue, due at Due.
+type Pending struct {
+	Id           bson.ObjectId `bson:"_id"`
+	Subscription bson.ObjectId `bson:"subscription"`
+	Event        string        `bson:"event"`
+	EventID      string        `bson:"event_id"`
+	Body         []byte        `bson:"body"`
+	Attempt      int           `bson:"attempt"`
+	Due          time.Time     `bson:"due"`
+}